@@ -0,0 +1,284 @@
+package reaper
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Session (process group) setup for the forked child.
+type SessionMode string
+
+const (
+	// New session (the default) - the child is detached from the parent's
+	// controlling terminal.
+	SessionSetsid SessionMode = "setsid"
+
+	// New process group in the parent's session.
+	SessionSetpgid SessionMode = "setpgid"
+
+	// Same session and process group as the parent.
+	SessionNone SessionMode = "none"
+)
+
+// Environment for the forked child, applied in order - start with the
+// parent's environment (or an empty one if cleared), keep only the allowed
+// variables (if any), remove and then add variables.
+type ChildEnv struct {
+	Clear  bool
+	Allow  []string
+	Remove []string
+
+	// Variables to add (KEY=value).
+	Add []string
+}
+
+// User and groups for the forked child to run as.
+type ChildUser struct {
+	Uid    uint32
+	Gid    uint32
+	Groups []uint32
+}
+
+// Process attributes for the forked child (`RunForked`/`WithReaper`).
+type ChildConfig struct {
+	// Working directory, defaults to the parent's working directory.
+	Dir string
+
+	// Environment for the child.
+	Env ChildEnv
+
+	// Session setup, defaults to a new session (setsid).
+	Session SessionMode
+
+	// Drop to this user and groups - nil runs as the parent's user.
+	User *ChildUser
+
+	// File mode creation mask, nil keeps the inherited umask.
+	Umask *os.FileMode
+
+	// Nice value (scheduling priority), 0 keeps the inherited value. Set
+	// for all the threads of the child (on linux, nice is per thread).
+	Nice int
+
+	// Connect stdin to /dev/null instead of the parent's stdin.
+	StdinNull bool
+
+	// Extra files passed to the child as fds 3, 4 ... (after the
+	// listeners, if any).
+	ExtraFiles []*os.File
+
+	// Signal the child gets if the parent dies (linux only), 0 disables
+	// it. Defaults to SIGKILL with `MakeConfig`.
+	ParentDeathSignal syscall.Signal
+
+	// Called in the child if the reaper parent is gone (the child got
+	// reparented), checked at startup and then periodically if the check
+	// interval is set. Defaults to logging a warning.
+	OnOrphaned          func(parent int)
+	OrphanCheckInterval time.Duration
+
+	// Interactive TTY mode - the child is put in its own process group in
+	// the foreground of the parent's terminal (stdin), instead of in a new
+	// session. Ignored if stdin is not a terminal.
+	TTY bool
+}
+
+// Return the name of an environment variable (KEY=value).
+func envName(kv string) string {
+	if idx := strings.IndexByte(kv, '='); idx >= 0 {
+		return kv[:idx]
+	}
+
+	return kv
+
+} /*  End of function  envName.  */
+
+// Build the environment for the child.
+func (e ChildEnv) build() []string {
+	base := os.Environ()
+	if e.Clear {
+		base = []string{}
+	}
+
+	allowed := make(map[string]bool, len(e.Allow))
+	for _, name := range e.Allow {
+		allowed[name] = true
+	}
+
+	removed := make(map[string]bool, len(e.Remove))
+	for _, name := range e.Remove {
+		removed[name] = true
+	}
+
+	env := make([]string, 0, len(base)+len(e.Add))
+	for _, kv := range base {
+		name := envName(kv)
+		if len(allowed) > 0 && !allowed[name] {
+			continue
+		}
+
+		if !removed[name] {
+			env = append(env, kv)
+		}
+	}
+
+	return append(env, e.Add...)
+
+} /*  End of method  ChildEnv.build.  */
+
+// Return the system process attributes for the child.
+func (c ChildConfig) sysProcAttr() (*syscall.SysProcAttr, error) {
+	sys := &syscall.SysProcAttr{}
+
+	switch {
+	case c.TTY && isTerminal(syscall.Stdin):
+		sys.Setpgid = true
+		sys.Foreground = true
+		sys.Ctty = syscall.Stdin
+
+	case c.Session == "", c.Session == SessionSetsid:
+		sys.Setsid = true
+
+	case c.Session == SessionSetpgid:
+		sys.Setpgid = true
+
+	case c.Session == SessionNone:
+
+	default:
+		return nil, fmt.Errorf("unknown session mode %q", c.Session)
+	}
+
+	if c.ParentDeathSignal != 0 {
+		setParentDeathSignal(sys, c.ParentDeathSignal)
+	}
+
+	if c.User != nil {
+		sys.Credential = &syscall.Credential{
+			Uid:    c.User.Uid,
+			Gid:    c.User.Gid,
+			Groups: c.User.Groups,
+		}
+	}
+
+	return sys, nil
+
+} /*  End of method  ChildConfig.sysProcAttr.  */
+
+// Return the files (fds) for the child and the files opened for it - the
+// caller keeps those referenced (open) for the lifetime of the parent, so
+// that they are passed to the restarted child as well.
+func (c ChildConfig) files() ([]uintptr, []*os.File, error) {
+	stdin := uintptr(syscall.Stdin)
+	opened := make([]*os.File, 0, 1)
+	if c.StdinNull {
+		devnull, err := os.Open(os.DevNull)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		stdin = devnull.Fd()
+		opened = append(opened, devnull)
+	}
+
+	files := []uintptr{stdin, uintptr(syscall.Stdout), uintptr(syscall.Stderr)}
+	for _, f := range c.ExtraFiles {
+		files = append(files, f.Fd())
+	}
+
+	return files, opened, nil
+
+} /*  End of method  ChildConfig.files.  */
+
+// Apply the child settings that are applied by the forked child itself
+// (the umask), called in the child before it returns from `RunForked`.
+func (c ChildConfig) apply() {
+	if c.Umask != nil {
+		syscall.Umask(int(*c.Umask & os.ModePerm))
+	}
+
+} /*  End of method  ChildConfig.apply.  */
+
+// Set the nice value of a process. On linux, nice is per thread and the
+// (re-executed) child already has its runtime threads, so set it for all
+// the threads (tasks) - until no new ones show up.
+func setNice(pid, nice int) error {
+	err := syscall.Setpriority(syscall.PRIO_PROCESS, pid, nice)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(procRoot, strconv.Itoa(pid), "task")
+	done := map[int]bool{pid: true}
+	for changed := true; changed; {
+		changed = false
+
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil /*  No /proc, just the process then.  */
+		}
+
+		for _, entry := range entries {
+			tid, err := strconv.Atoi(entry.Name())
+			if err != nil || done[tid] {
+				continue
+			}
+
+			/*  The thread may be gone already.  */
+			syscall.Setpriority(syscall.PRIO_PROCESS, tid, nice)
+			done[tid] = true
+			changed = true
+		}
+	}
+
+	return nil
+
+} /*  End of function  setNice.  */
+
+// Handle the reaper parent being gone.
+func (c ChildConfig) orphaned(parent int) {
+	if c.OnOrphaned != nil {
+		c.OnOrphaned(parent)
+		return
+	}
+
+	fmt.Printf(" - Warning: reaper parent %d is gone, pid %d orphaned\n",
+		parent, os.Getpid())
+
+} /*  End of method  ChildConfig.orphaned.  */
+
+// Watch the reaper parent - value is the env indicator value (the parent
+// pid) passed to the child.
+func (c ChildConfig) watchParent(value string) {
+	parent, err := strconv.Atoi(value)
+	if err != nil || parent <= 0 {
+		return
+	}
+
+	if os.Getppid() != parent {
+		c.orphaned(parent)
+		return
+	}
+
+	if c.OrphanCheckInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(c.OrphanCheckInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if os.Getppid() != parent {
+				c.orphaned(parent)
+				return
+			}
+		}
+	}()
+
+} /*  End of method  ChildConfig.watchParent.  */
@@ -0,0 +1,295 @@
+package reaper
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Env variable that turns the test binary into a helper process.
+const helperProcessEnv = "REAPER_TEST_HELPER_PROCESS"
+
+// Not a real test - a helper process (a Go program with its runtime
+// threads) started by the tests.
+func TestHelperProcess(t *testing.T) {
+	switch os.Getenv(helperProcessEnv) {
+	case "1":
+
+	case "pdeathsig":
+		/*  Start a child with the parent death signal.  */
+		config := ChildConfig{ParentDeathSignal: syscall.SIGKILL}
+		sys, _ := config.sysProcAttr()
+		attrs := &syscall.ProcAttr{Sys: sys}
+
+		pid, err := syscall.ForkExec("/bin/sleep", []string{"sleep", "30"},
+			attrs)
+		if err != nil {
+			os.Exit(1)
+		}
+
+		os.Stdout.WriteString(strconv.Itoa(pid) + "\n")
+
+	case "pid1signals":
+		/*  Exit with 128+signal on the default pid 1 signals.  */
+		r := &Reaper{}
+		r.config.Pid1Signals.Enable = true
+		r.handlePid1Signals()
+
+		os.Stdout.WriteString("ready\n")
+
+	default:
+		return
+	}
+
+	time.Sleep(30 * time.Second)
+	os.Exit(0)
+
+} /*  End of function  TestHelperProcess.  */
+
+// Start a helper process.
+func startHelperProcess(t *testing.T, mode string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(), helperProcessEnv+"="+mode)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start helper process: %v", err)
+	}
+
+	return cmd
+
+} /*  End of function  startHelperProcess.  */
+
+// Build the child environment - clear, allow, remove and add.
+func TestChildEnvBuild(t *testing.T) {
+	os.Setenv("REAPER_TEST_KEEP", "1")
+	os.Setenv("REAPER_TEST_DROP", "1")
+	defer os.Unsetenv("REAPER_TEST_KEEP")
+	defer os.Unsetenv("REAPER_TEST_DROP")
+
+	env := ChildEnv{Clear: true, Add: []string{"A=1"}}.build()
+	if !reflect.DeepEqual(env, []string{"A=1"}) {
+		t.Errorf("cleared env = %v, expected [A=1]", env)
+	}
+
+	env = ChildEnv{
+		Allow:  []string{"REAPER_TEST_KEEP", "REAPER_TEST_DROP"},
+		Remove: []string{"REAPER_TEST_DROP"},
+		Add:    []string{"B=2"},
+	}.build()
+
+	expected := []string{"REAPER_TEST_KEEP=1", "B=2"}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("env = %v, expected %v", env, expected)
+	}
+
+	env = ChildEnv{Remove: []string{"REAPER_TEST_DROP"}}.build()
+	joined := "\n" + strings.Join(env, "\n") + "\n"
+	if !strings.Contains(joined, "\nREAPER_TEST_KEEP=1\n") ||
+		strings.Contains(joined, "\nREAPER_TEST_DROP=") {
+		t.Errorf("env without REAPER_TEST_DROP = %v", env)
+	}
+
+} /*  End of function  TestChildEnvBuild.  */
+
+// Session modes and credentials of the child.
+func TestChildSysProcAttr(t *testing.T) {
+	sys, err := ChildConfig{}.sysProcAttr()
+	if err != nil || !sys.Setsid || sys.Setpgid {
+		t.Errorf("default session = %+v, %v", sys, err)
+	}
+
+	sys, err = ChildConfig{Session: SessionSetpgid}.sysProcAttr()
+	if err != nil || sys.Setsid || !sys.Setpgid {
+		t.Errorf("setpgid session = %+v, %v", sys, err)
+	}
+
+	sys, err = ChildConfig{Session: SessionNone}.sysProcAttr()
+	if err != nil || sys.Setsid || sys.Setpgid {
+		t.Errorf("no session = %+v, %v", sys, err)
+	}
+
+	if _, err := (ChildConfig{Session: "bogus"}).sysProcAttr(); err == nil {
+		t.Errorf("expected an error for an unknown session mode")
+	}
+
+	user := &ChildUser{Uid: 42, Gid: 7, Groups: []uint32{8}}
+	sys, _ = ChildConfig{User: user}.sysProcAttr()
+	if sys.Credential == nil || sys.Credential.Uid != 42 ||
+		sys.Credential.Gid != 7 {
+		t.Errorf("credential = %+v", sys.Credential)
+	}
+
+} /*  End of function  TestChildSysProcAttr.  */
+
+// A zero umask can be set, nil keeps the inherited one.
+func TestChildUmask(t *testing.T) {
+	saved := syscall.Umask(022)
+	defer syscall.Umask(saved)
+
+	ChildConfig{}.apply()
+	if mask := syscall.Umask(022); mask != 022 {
+		t.Errorf("nil umask changed the umask to %#o", mask)
+	}
+
+	zero := os.FileMode(0)
+	ChildConfig{Umask: &zero}.apply()
+	if mask := syscall.Umask(022); mask != 0 {
+		t.Errorf("umask = %#o, expected 0", mask)
+	}
+
+} /*  End of function  TestChildUmask.  */
+
+// Nice is set for all the threads of a (Go) child process.
+func TestSetNice(t *testing.T) {
+	cmd := startHelperProcess(t, "1")
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	pid := cmd.Process.Pid
+	dir := filepath.Join(procRoot, strconv.Itoa(pid), "task")
+
+	//  Wait for the runtime threads to start up.
+	time.Sleep(200 * time.Millisecond)
+	if err := setNice(pid, 7); err != nil {
+		t.Fatalf("set nice: %v", err)
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Skip("no /proc filesystem")
+	}
+
+	if len(entries) < 2 {
+		t.Logf("helper process has only %d threads", len(entries))
+	}
+
+	for _, entry := range entries {
+		data, err := ioutil.ReadFile(filepath.Join(dir, entry.Name(),
+			"stat"))
+		if err != nil {
+			continue
+		}
+
+		//  Field 19 (nice) - the 17th field after the command name.
+		stat := string(data)
+		fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+		if len(fields) > 16 && fields[16] != "7" {
+			t.Errorf("thread %s nice = %s, expected 7", entry.Name(),
+				fields[16])
+		}
+	}
+
+} /*  End of function  TestSetNice.  */
+
+// The child is killed when its parent dies.
+func TestParentDeathSignal(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("parent death signal is linux only")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(), helperProcessEnv+"=pdeathsig")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("helper stdout: %v", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start helper process: %v", err)
+	}
+
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	pid, _ := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || pid <= 0 {
+		cmd.Process.Kill()
+		cmd.Wait()
+		t.Fatalf("helper child pid %q: %v", line, err)
+	}
+
+	cmd.Process.Kill()
+	cmd.Wait()
+
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		info, err := readProcess(pid)
+		if err != nil || info.State == "Z" {
+			break /*  Gone or dead (not yet reaped by init).  */
+		}
+
+		if time.Now().After(deadline) {
+			syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("child %d survived its parent", pid)
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+
+} /*  End of function  TestParentDeathSignal.  */
+
+// The orphaned hook is called if the parent is not the reaper parent.
+func TestWatchParent(t *testing.T) {
+	orphaned := make(chan int, 1)
+	config := ChildConfig{
+		OnOrphaned:          func(parent int) { orphaned <- parent },
+		OrphanCheckInterval: 10 * time.Millisecond,
+	}
+
+	config.watchParent(strconv.Itoa(os.Getppid()))
+	select {
+	case parent := <-orphaned:
+		t.Errorf("orphaned from our own parent %d", parent)
+
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	notParent := os.Getppid() + 424242
+	config.watchParent(strconv.Itoa(notParent))
+	select {
+	case parent := <-orphaned:
+		if parent != notParent {
+			t.Errorf("orphaned parent = %d, expected %d", parent,
+				notParent)
+		}
+
+	case <-time.After(time.Second):
+		t.Errorf("orphaned hook was not called")
+	}
+
+	config.watchParent("not-a-pid")
+
+} /*  End of function  TestWatchParent.  */
+
+// The /dev/null stdin of the forked child stays open (across a GC) for
+// its restarts.
+func TestStdinNullKeptOpen(t *testing.T) {
+	config := MakeConfig()
+	config.Child.StdinNull = true
+
+	p, err := forkedChild(config)
+	if err != nil {
+		t.Fatalf("forked child: %v", err)
+	}
+
+	runtime.GC()
+	runtime.GC()
+
+	var stat, null syscall.Stat_t
+	if err := syscall.Fstat(int(p.attrs.Files[0]), &stat); err != nil {
+		t.Fatalf("stdin fd %d: %v", p.attrs.Files[0], err)
+	}
+
+	syscall.Stat(os.DevNull, &null)
+	if stat.Rdev != null.Rdev || stat.Ino != null.Ino {
+		t.Errorf("stdin fd %d is not %v", p.attrs.Files[0], os.DevNull)
+	}
+
+} /*  End of function  TestStdinNullKeptOpen.  */
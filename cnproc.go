@@ -0,0 +1,177 @@
+//go:build linux
+// +build linux
+
+package reaper
+
+/*  Note:  This is a linux only implementation [netlink proc connector]. */
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// Proc connector ids and operations from linux/connector.h and
+	// linux/cn_proc.h
+	cnIdxProc         = 0x1
+	cnValProc         = 0x1
+	procCnMcastListen = 0x1
+
+	procEventFork = 0x00000001
+	procEventExec = 0x00000002
+	procEventExit = 0x80000000
+
+	// Sizes of struct cn_msg and the proc_event header.
+	cnMsgSize       = 20
+	procEventHeader = 16
+
+	// Socket receive buffer size - fork storms can be bursty.
+	cnRecvBufferSize = 1 << 20
+)
+
+// Native byte order - netlink messages are in host byte order.
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+
+	return binary.BigEndian
+}()
+
+// Make the proc connector multicast listen request.
+func makeListenRequest() []byte {
+	size := syscall.NLMSG_HDRLEN + cnMsgSize + 4
+	buf := make([]byte, size)
+
+	/*  struct nlmsghdr  */
+	nativeEndian.PutUint32(buf[0:], uint32(size))
+	nativeEndian.PutUint16(buf[4:], syscall.NLMSG_DONE)
+	nativeEndian.PutUint32(buf[12:], uint32(os.Getpid()))
+
+	/*  struct cn_msg  */
+	msg := buf[syscall.NLMSG_HDRLEN:]
+	nativeEndian.PutUint32(msg[0:], cnIdxProc)
+	nativeEndian.PutUint32(msg[4:], cnValProc)
+	nativeEndian.PutUint16(msg[16:], 4)
+
+	/*  enum proc_cn_mcast_op  */
+	nativeEndian.PutUint32(msg[cnMsgSize:], procCnMcastListen)
+
+	return buf
+
+} /*  End of function  makeListenRequest.  */
+
+// Dispatch a proc_event to the tracker.
+func (c *procConnector) dispatch(data []byte) {
+	if len(data) < cnMsgSize+procEventHeader {
+		return
+	}
+
+	event := data[cnMsgSize:]
+	what := nativeEndian.Uint32(event[0:])
+	body := event[procEventHeader:]
+
+	field := func(idx int) int {
+		offset := idx * 4
+		if len(body) < offset+4 {
+			return 0
+		}
+
+		return int(nativeEndian.Uint32(body[offset:]))
+	}
+
+	switch what {
+	case procEventFork:
+		/*  parent_pid, parent_tgid, child_pid, child_tgid  */
+		if pid := field(2); pid == field(3) {
+			c.forked(pid, field(1))
+		}
+
+	case procEventExec:
+		/*  process_pid, process_tgid  */
+		if pid := field(0); pid == field(1) {
+			c.execd(pid)
+		}
+
+	case procEventExit:
+		/*  process_pid, process_tgid, exit_code, exit_signal,
+		 *  parent_pid, parent_tgid
+		 */
+		if pid := field(0); pid == field(1) {
+			c.exited(pid, field(5), field(2), field(3))
+		}
+	}
+
+} /*  End of method  procConnector.dispatch.  */
+
+// Subscribe to the proc connector and process events. The kernel only
+// honours the subscription from the initial pid and user namespaces (and
+// the events carry the initial namespace pids), so this fails in a
+// container.
+func (c *procConnector) listen() error {
+	if err := inInitialNamespaces(); err != nil {
+		return fmt.Errorf("proc connector unavailable: %v", err)
+	}
+
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC,
+		unix.NETLINK_CONNECTOR)
+	if err != nil {
+		return fmt.Errorf("netlink socket: %v", err)
+	}
+
+	defer unix.Close(fd)
+
+	addr := &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: cnIdxProc}
+	if err := unix.Bind(fd, addr); err != nil {
+		return fmt.Errorf("netlink bind: %v", err)
+	}
+
+	//  Best effort, the default buffer size still works.
+	_ = unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_RCVBUF,
+		cnRecvBufferSize)
+
+	kernel := &unix.SockaddrNetlink{Family: unix.AF_NETLINK}
+	if err := unix.Sendto(fd, makeListenRequest(), 0, kernel); err != nil {
+		return fmt.Errorf("proc connector listen: %v", err)
+	}
+
+	if c.reaper.config.Debug {
+		fmt.Println(" - Proc connector listening ...")
+	}
+
+	buf := make([]byte, os.Getpagesize()*4)
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err == unix.EINTR {
+			continue
+		}
+
+		if err == unix.ENOBUFS {
+			/*  Overrun - lost some events, keep going.  */
+			fmt.Println(" - Proc connector overrun, lost events")
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("proc connector receive: %v", err)
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+
+		for _, m := range msgs {
+			if m.Header.Type == syscall.NLMSG_DONE {
+				c.dispatch(m.Data)
+			}
+		}
+	}
+
+} /*  End of method  procConnector.listen.  */
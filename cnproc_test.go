@@ -0,0 +1,214 @@
+//go:build linux
+// +build linux
+
+package reaper
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Fake pids (above the max pid_max), so that there is nothing in /proc.
+const (
+	fakeParent = 1<<22 + 1
+	fakeChild  = 1<<22 + 2
+	fakeKid    = 1<<22 + 3
+)
+
+// Make a cn_msg with a proc_event of the specified type and fields.
+func procEventMsg(what uint32, fields ...int) []byte {
+	data := make([]byte, cnMsgSize+procEventHeader+4*len(fields))
+	nativeEndian.PutUint32(data[cnMsgSize:], what)
+
+	body := data[cnMsgSize+procEventHeader:]
+	for idx, field := range fields {
+		nativeEndian.PutUint32(body[idx*4:], uint32(field))
+	}
+
+	return data
+
+} /*  End of function  procEventMsg.  */
+
+// Make a fork event.
+func forkMsg(pid, parent int) []byte {
+	return procEventMsg(procEventFork, parent, parent, pid, pid)
+
+} /*  End of function  forkMsg.  */
+
+// Make an exit event.
+func exitMsg(pid, parent int) []byte {
+	return procEventMsg(procEventExit, pid, pid, 0, 17, parent, parent)
+
+} /*  End of function  exitMsg.  */
+
+// Make a proc connector for a reaper with our pid.
+func testProcConnector() (*procConnector, chan Event) {
+	events := make(chan Event, 16)
+
+	r := &Reaper{pid: os.Getpid()}
+	r.config.EventChannel = events
+	r.config.ProcConnector.PublishEvents = true
+
+	return newProcConnector(r), events
+
+} /*  End of function  testProcConnector.  */
+
+// Return the types of the published events.
+func eventTypes(events chan Event) []EventType {
+	types := make([]EventType, 0)
+	for len(events) > 0 {
+		types = append(types, (<-events).Type)
+	}
+
+	return types
+
+} /*  End of function  eventTypes.  */
+
+// Dispatch the proc events and check the lineage claimed for a pid.
+func TestProcConnectorDispatch(t *testing.T) {
+	self := os.Getpid()
+	exec := procEventMsg(procEventExec, fakeChild, fakeChild)
+
+	tests := []struct {
+		name       string
+		msgs       [][]byte
+		pid        int
+		parent     int
+		reparented bool
+		events     []EventType
+	}{
+		{
+			name: "fork exec exit",
+			msgs: [][]byte{
+				forkMsg(fakeChild, self),
+				exec,
+				exitMsg(fakeChild, self),
+			},
+			pid:    fakeChild,
+			parent: self,
+			events: []EventType{EventFork, EventExec, EventExit},
+		},
+		{
+			name:   "reaped without an exit event",
+			msgs:   [][]byte{forkMsg(fakeChild, self)},
+			pid:    fakeChild,
+			parent: self,
+			events: []EventType{EventFork},
+		},
+		{
+			name: "exit of a process that is not ours",
+			msgs: [][]byte{
+				forkMsg(fakeChild, fakeParent),
+				exitMsg(fakeChild, fakeParent),
+			},
+			pid:    fakeChild,
+			events: []EventType{EventFork, EventExit},
+		},
+		{
+			name: "reparented on the parent exit",
+			msgs: [][]byte{
+				forkMsg(fakeChild, fakeParent),
+				forkMsg(fakeKid, fakeChild),
+				exitMsg(fakeChild, fakeParent),
+				exitMsg(fakeKid, self),
+			},
+			pid:        fakeKid,
+			parent:     fakeChild,
+			reparented: true,
+			events: []EventType{EventFork, EventFork, EventExit,
+				EventReparent, EventExit},
+		},
+		{
+			name: "pid reuse",
+			msgs: [][]byte{
+				forkMsg(fakeChild, fakeParent),
+				forkMsg(fakeChild, self),
+			},
+			pid:    fakeChild,
+			parent: self,
+			events: []EventType{EventFork, EventFork},
+		},
+		{
+			name: "threads, short and unknown messages",
+			msgs: [][]byte{
+				procEventMsg(procEventFork, 1, 1, fakeChild, fakeParent),
+				procEventMsg(procEventExit, fakeChild, fakeParent),
+				procEventMsg(0x42, fakeChild, fakeChild),
+				forkMsg(fakeChild, 1)[:cnMsgSize+procEventHeader-1],
+			},
+			pid:    fakeChild,
+			events: []EventType{},
+		},
+	}
+
+	for _, tt := range tests {
+		c, events := testProcConnector()
+		for _, msg := range tt.msgs {
+			c.dispatch(msg)
+		}
+
+		if types := eventTypes(events); !reflect.DeepEqual(types,
+			tt.events) {
+			t.Errorf("%s: events = %v, expected %v", tt.name, types,
+				tt.events)
+		}
+
+		lineage := c.claim(tt.pid)
+		switch {
+		case tt.parent == 0 && lineage != nil:
+			t.Errorf("%s: lineage = %+v, expected none", tt.name,
+				lineage)
+
+		case tt.parent == 0:
+
+		case lineage == nil:
+			t.Errorf("%s: no lineage for pid %d", tt.name, tt.pid)
+
+		case lineage.Parent != tt.parent ||
+			lineage.Reparented != tt.reparented ||
+			lineage.ParentComm != readComm(tt.parent) ||
+			lineage.Forked.IsZero():
+			t.Errorf("%s: lineage = %+v, expected parent %d, "+
+				"reparented %v", tt.name, lineage, tt.parent,
+				tt.reparented)
+		}
+
+		if lineage := c.claim(tt.pid); lineage != nil {
+			t.Errorf("%s: lineage claimed twice", tt.name)
+		}
+
+		if len(c.processes) > 0 || len(c.children) > 0 {
+			t.Errorf("%s: left over processes %v, children %v", tt.name,
+				c.processes, c.children)
+		}
+	}
+
+} /*  End of function  TestProcConnectorDispatch.  */
+
+// Exited processes that are never reaped are pruned.
+func TestProcConnectorPrune(t *testing.T) {
+	c, _ := testProcConnector()
+	self := os.Getpid()
+
+	c.dispatch(forkMsg(fakeChild, self))
+	c.dispatch(exitMsg(fakeChild, self))
+
+	c.mutex.Lock()
+	c.processes[fakeChild].exited = time.Now().Add(-2 * lineageRetention)
+	c.pruned = time.Time{}
+	c.mutex.Unlock()
+
+	c.dispatch(forkMsg(fakeKid, self))
+	c.dispatch(exitMsg(fakeKid, self))
+
+	if lineage := c.claim(fakeChild); lineage != nil {
+		t.Errorf("stale lineage was not pruned: %+v", lineage)
+	}
+
+	if lineage := c.claim(fakeKid); lineage == nil {
+		t.Errorf("recent lineage for pid %d was pruned", fakeKid)
+	}
+
+} /*  End of function  TestProcConnectorPrune.  */
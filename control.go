@@ -0,0 +1,205 @@
+package reaper
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Control message type.
+type ControlMessageType string
+
+const (
+	// Child to parent - the child is ready, wants to be restarted, wants
+	// a graceful shutdown (of the container) or sets the exit code.
+	ControlReady    ControlMessageType = "ready"
+	ControlRestart  ControlMessageType = "restart"
+	ControlShutdown ControlMessageType = "shutdown"
+	ControlExitCode ControlMessageType = "exit-code"
+
+	// Parent to child - the parent is shutting down, the child is stopped
+	// at the deadline (if any).
+	ControlStopping ControlMessageType = "stopping"
+
+	// Env variable with the control socket file descriptor number passed
+	// to the child process.
+	ControlFdEnv = "REAPER_CONTROL_FD"
+)
+
+// Control channel configuration - the forked child (and the programs) get
+// a control socket to the parent.
+type ControlConfig struct {
+	// Enable the control channel.
+	Enable bool
+}
+
+// Control message (a json line) exchanged over the control socket.
+type ControlMessage struct {
+	Type     ControlMessageType `json:"type"`
+	ExitCode int                `json:"exitCode,omitempty"`
+	Deadline *time.Time         `json:"deadline,omitempty"`
+}
+
+// Control message received from a program (run).
+type controlRequest struct {
+	programRun
+	message ControlMessage
+}
+
+// Callback entry point [function] for WithReaperControl.
+type ControlEntryPoint func(control *ControlClient, err error) int
+
+// Make a connected socket pair (for the control channel).
+func controlSocketPair() (*os.File, *os.File, error) {
+	syscall.ForkLock.RLock()
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err == nil {
+		syscall.CloseOnExec(fds[0])
+		syscall.CloseOnExec(fds[1])
+	}
+	syscall.ForkLock.RUnlock()
+
+	if err != nil {
+		return nil, nil, os.NewSyscallError("socketpair", err)
+	}
+
+	return os.NewFile(uintptr(fds[0]), "reaper-control"),
+		os.NewFile(uintptr(fds[1]), "reaper-control-child"), nil
+
+} /*  End of function  controlSocketPair.  */
+
+// Make a control connection from a socket file - the file is closed.
+func controlConn(f *os.File) (net.Conn, error) {
+	defer f.Close()
+
+	return net.FileConn(f)
+
+} /*  End of function  controlConn.  */
+
+// Read control messages (json lines) from a connection until EOF.
+func readControl(conn net.Conn, fn func(msg ControlMessage)) {
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var msg ControlMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err == nil {
+			fn(msg)
+		}
+	}
+
+} /*  End of function  readControl.  */
+
+// Write a control message (json line) to a connection.
+func writeControl(conn net.Conn, msg ControlMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Write(append(data, '\n'))
+	return err
+
+} /*  End of function  writeControl.  */
+
+/*
+ *  ======================================================================
+ *  Section: Control client (used in the child).
+ *  ======================================================================
+ */
+
+// Control channel client - used in the child to talk to the reaper parent.
+type ControlClient struct {
+	conn     net.Conn
+	mutex    sync.Mutex
+	stopping chan ControlMessage
+}
+
+// Connect to the reaper parent using the control socket passed in the
+// `REAPER_CONTROL_FD` env variable.
+func NewControlClient() (*ControlClient, error) {
+	value, ok := os.LookupEnv(ControlFdEnv)
+	if !ok {
+		return nil, fmt.Errorf("no control socket (%s not set)", ControlFdEnv)
+	}
+
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s=%q: %v", ControlFdEnv, value, err)
+	}
+
+	//  Don't leak the control socket to our children.
+	os.Unsetenv(ControlFdEnv)
+
+	conn, err := controlConn(os.NewFile(uintptr(fd), "reaper-control"))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &ControlClient{conn: conn, stopping: make(chan ControlMessage, 1)}
+	go func() {
+		readControl(conn, func(msg ControlMessage) {
+			if msg.Type != ControlStopping {
+				return
+			}
+
+			select {
+			case c.stopping <- msg:
+			default:
+			}
+		})
+	}()
+
+	return c, nil
+
+} /*  End of [exported] function  NewControlClient.  */
+
+// Send a control message to the parent.
+func (c *ControlClient) send(msg ControlMessage) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return writeControl(c.conn, msg)
+
+} /*  End of method  ControlClient.send.  */
+
+// Report that the child is ready.
+func (c *ControlClient) Ready() error {
+	return c.send(ControlMessage{Type: ControlReady})
+
+} /*  End of [exported] method  ControlClient.Ready.  */
+
+// Ask the parent to restart the child.
+func (c *ControlClient) RequestRestart() error {
+	return c.send(ControlMessage{Type: ControlRestart})
+
+} /*  End of [exported] method  ControlClient.RequestRestart.  */
+
+// Ask the parent for a graceful shutdown (of the container).
+func (c *ControlClient) RequestShutdown() error {
+	return c.send(ControlMessage{Type: ControlShutdown})
+
+} /*  End of [exported] method  ControlClient.RequestShutdown.  */
+
+// Set the exit code the parent exits with.
+func (c *ControlClient) SetExitCode(code int) error {
+	return c.send(ControlMessage{Type: ControlExitCode, ExitCode: code})
+
+} /*  End of [exported] method  ControlClient.SetExitCode.  */
+
+// Return a channel on which the parent's shutdown notice is delivered -
+// the message has the deadline (if any) after which the child is killed.
+func (c *ControlClient) Stopping() <-chan ControlMessage {
+	return c.stopping
+
+} /*  End of [exported] method  ControlClient.Stopping.  */
+
+// Close the control channel.
+func (c *ControlClient) Close() error {
+	return c.conn.Close()
+
+} /*  End of [exported] method  ControlClient.Close.  */
@@ -0,0 +1,105 @@
+package reaper
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Control client errors without a (valid) control socket.
+func TestControlClientEnv(t *testing.T) {
+	defer os.Unsetenv(ControlFdEnv)
+
+	os.Unsetenv(ControlFdEnv)
+	if _, err := NewControlClient(); err == nil {
+		t.Errorf("control client without %s, expected error", ControlFdEnv)
+	}
+
+	os.Setenv(ControlFdEnv, "not-a-fd")
+	if _, err := NewControlClient(); err == nil {
+		t.Errorf("control client with an invalid fd, expected error")
+	}
+
+} /*  End of function  TestControlClientEnv.  */
+
+// Control messages between the parent and the child.
+func TestControlChannel(t *testing.T) {
+	parentEnd, childEnd, err := controlSocketPair()
+	if err != nil {
+		t.Fatalf("control socket pair: %v", err)
+	}
+
+	//  The client takes over the fd passed in the env variable.
+	fd, err := syscall.Dup(int(childEnd.Fd()))
+	childEnd.Close()
+	if err != nil {
+		t.Fatalf("dup: %v", err)
+	}
+
+	conn, err := controlConn(parentEnd)
+	if err != nil {
+		t.Fatalf("control conn: %v", err)
+	}
+
+	defer conn.Close()
+
+	os.Setenv(ControlFdEnv, strconv.Itoa(fd))
+	client, err := NewControlClient()
+	if err != nil {
+		t.Fatalf("control client: %v", err)
+	}
+
+	defer client.Close()
+
+	if _, ok := os.LookupEnv(ControlFdEnv); ok {
+		t.Errorf("control client did not unset %s", ControlFdEnv)
+	}
+
+	messages := make(chan ControlMessage, 4)
+	go readControl(conn, func(msg ControlMessage) { messages <- msg })
+
+	client.Ready()
+	client.RequestRestart()
+	client.SetExitCode(7)
+	client.RequestShutdown()
+
+	for _, expected := range []ControlMessage{
+		{Type: ControlReady},
+		{Type: ControlRestart},
+		{Type: ControlExitCode, ExitCode: 7},
+		{Type: ControlShutdown},
+	} {
+		select {
+		case msg := <-messages:
+			if msg.Type != expected.Type ||
+				msg.ExitCode != expected.ExitCode {
+				t.Errorf("message = %+v, expected %+v", msg, expected)
+			}
+
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %+v", expected)
+		}
+	}
+
+	deadline := time.Now().Add(time.Minute).Round(time.Second)
+	conn.Write([]byte("not json\n"))
+	writeControl(conn, ControlMessage{Type: ControlReady})
+	writeControl(conn, ControlMessage{
+		Type:     ControlStopping,
+		Deadline: &deadline,
+	})
+
+	select {
+	case msg := <-client.Stopping():
+		if msg.Deadline == nil || !msg.Deadline.Equal(deadline) {
+			t.Errorf("stopping deadline = %v, expected %v",
+				msg.Deadline, deadline)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the stopping message")
+	}
+
+} /*  End of function  TestControlChannel.  */
@@ -7,6 +7,9 @@ package reaper
 
 import (
 	"fmt"
+	"syscall"
+
+	"golang.org/x/sys/unix"
 )
 
 // Enable child subreaper.
@@ -14,3 +17,33 @@ func EnableChildSubReaper() error {
 	return fmt.Errorf("child subreaper not supported on darwin")
 
 } /*  End of [exported] function  EnableChildSubReaper.  */
+
+// Proc connector event source.
+func (c *procConnector) listen() error {
+	return fmt.Errorf("proc connector not supported on darwin")
+
+} /*  End of method  procConnector.listen.  */
+
+// Peek at an exited child process without reaping it.
+func peekChild(pid int, options int) (int, error) {
+	return 0, syscall.ENOSYS
+
+} /*  End of function  peekChild.  */
+
+// Set the signal the child gets when the parent dies - not supported.
+func setParentDeathSignal(sys *syscall.SysProcAttr, sig syscall.Signal) {
+
+} /*  End of function  setParentDeathSignal.  */
+
+// Check if a file descriptor is a terminal.
+func isTerminal(fd int) bool {
+	_, err := unix.IoctlGetTermios(fd, unix.TIOCGETA)
+	return err == nil
+
+} /*  End of function  isTerminal.  */
+
+// Make a process group the foreground process group of a terminal.
+func setForeground(fd, pgrp int) error {
+	return unix.IoctlSetPointerInt(fd, unix.TIOCSPGRP, pgrp)
+
+} /*  End of function  setForeground.  */
@@ -0,0 +1,88 @@
+package reaper
+
+import (
+	"fmt"
+	"time"
+)
+
+// Type of a reaper event.
+type EventType string
+
+const (
+	// A (non-reaper) parent process is neglecting its zombie children.
+	// The event data is a `NegligentParent`.
+	EventNegligentParent EventType = "negligent-parent"
+
+	// Pid usage crossed the warning threshold, high-water mark or
+	// dropped back below the warning threshold. The event data is a
+	// `PidBudgetAlert`.
+	EventPidBudgetWarning   EventType = "pid-budget-warning"
+	EventPidBudgetHighWater EventType = "pid-budget-high-water"
+	EventPidBudgetRecovered EventType = "pid-budget-recovered"
+
+	// Proc connector process lifecycle events. The event data is a
+	// `ProcEvent`.
+	EventFork     EventType = "fork"
+	EventExec     EventType = "exec"
+	EventExit     EventType = "exit"
+	EventReparent EventType = "reparent"
+
+	// Supervised child process is being restarted or is crash looping
+	// and will not be restarted. The event data is a `RestartInfo`.
+	EventRestart   EventType = "restart"
+	EventCrashLoop EventType = "crash-loop"
+
+	// Forked child was upgraded (the new copy is ready) or the upgrade
+	// failed and was rolled back. The event data is an `UpgradeInfo`.
+	EventUpgrade       EventType = "upgrade"
+	EventUpgradeFailed EventType = "upgrade-failed"
+
+	// Leftover descendants were torn down after the forked child exited.
+	// The event data is a `TeardownInfo`.
+	EventTeardown EventType = "teardown"
+)
+
+// Reaper event published on the `EventChannel`.
+type Event struct {
+	Type    EventType   `json:"type"`
+	Time    time.Time   `json:"time"`
+	Pid     int         `json:"pid"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Make an event of the specified type.
+func makeEvent(etype EventType, pid int, data interface{}, format string,
+	args ...interface{}) Event {
+	return Event{
+		Type:    etype,
+		Time:    time.Now(),
+		Pid:     pid,
+		Message: fmt.Sprintf(format, args...),
+		Data:    data,
+	}
+
+} /*  End of function  makeEvent.  */
+
+// Publish an event on the event `ch` channel.
+func publish(ch chan Event, event Event) {
+	if ch == nil {
+		return
+	}
+
+	//  Same caveats as `notify` - recover if the caller closes the
+	//  event channel on us.
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Printf(" - Recovering from publish panic: %v\n", r)
+			fmt.Printf(" - Lost event: %+v\n", event)
+		}
+	}()
+
+	select {
+	case ch <- event: /*  Published the event.  */
+	default: /*  blocked ... channel full or no reader!  */
+		fmt.Printf(" - Event channel full, lost event: %+v\n", event)
+	}
+
+} /*  End of function  publish.  */
@@ -0,0 +1,261 @@
+package reaper
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// How long to remember processes that exited but were not reaped by
+	// us (or were reaped without us being told about it).
+	lineageRetention = time.Minute
+)
+
+// Proc connector configuration. The proc connector is a linux kernel
+// event source (netlink CN_PROC) for fork, exec and exit events across
+// the system - it requires CAP_NET_ADMIN and only works in the initial pid
+// and user namespaces (aka not in a container).
+type ProcConnectorConfig struct {
+	// Enable the proc connector event source.
+	Enable bool
+
+	// Publish fork, exec, exit and reparent events on the EventChannel.
+	PublishEvents bool
+}
+
+// Lineage of a reaped process as recorded by the proc connector.
+type Lineage struct {
+	Parent     int       `json:"parent"`
+	ParentComm string    `json:"parentComm"`
+	Comm       string    `json:"comm"`
+	Forked     time.Time `json:"forked"`
+	Reparented bool      `json:"reparented"`
+}
+
+// Event data for the proc connector events.
+type ProcEvent struct {
+	Pid        int    `json:"pid"`
+	Parent     int    `json:"parent"`
+	Comm       string `json:"comm,omitempty"`
+	ExitStatus int    `json:"exitStatus,omitempty"`
+	ExitSignal int    `json:"exitSignal,omitempty"`
+}
+
+// Tracked process.
+type tracked struct {
+	lineage Lineage
+	parent  int
+	exited  time.Time
+}
+
+// Process lineage tracker fed by the proc connector.
+type procConnector struct {
+	config    ProcConnectorConfig
+	reaper    *Reaper
+	mutex     sync.Mutex
+	processes map[int]*tracked
+	children  map[int]map[int]bool
+	pruned    time.Time
+}
+
+// Make a new proc connector for the reaper.
+func newProcConnector(r *Reaper) *procConnector {
+	return &procConnector{
+		config:    r.config.ProcConnector,
+		reaper:    r,
+		processes: make(map[int]*tracked),
+		children:  make(map[int]map[int]bool),
+	}
+
+} /*  End of function  newProcConnector.  */
+
+// Return the command name of a process.
+func readComm(pid int) string {
+	path := filepath.Join(procRoot, strconv.Itoa(pid), "comm")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+
+} /*  End of function  readComm.  */
+
+// Publish a proc connector event if enabled.
+func (c *procConnector) publish(etype EventType, data ProcEvent) {
+	if !c.config.PublishEvents {
+		return
+	}
+
+	event := makeEvent(etype, data.Pid, data, "%s pid %d (%s) parent %d",
+		etype, data.Pid, data.Comm, data.Parent)
+
+	publish(c.reaper.config.EventChannel, event)
+
+} /*  End of method  procConnector.publish.  */
+
+// Link/unlink a process to its current parent - caller holds the lock.
+func (c *procConnector) link(pid, parent int, add bool) {
+	kids := c.children[parent]
+	if add {
+		if kids == nil {
+			kids = make(map[int]bool)
+			c.children[parent] = kids
+		}
+
+		kids[pid] = true
+		return
+	}
+
+	delete(kids, pid)
+	if len(kids) == 0 {
+		delete(c.children, parent)
+	}
+
+} /*  End of method  procConnector.link.  */
+
+// Record a fork event.
+func (c *procConnector) forked(pid, parent int) {
+	comm := readComm(parent)
+
+	c.mutex.Lock()
+	if p, ok := c.processes[parent]; ok && len(comm) == 0 {
+		/*  Parent already gone, use what we know about it.  */
+		comm = p.lineage.Comm
+	}
+
+	t := &tracked{
+		lineage: Lineage{
+			Parent:     parent,
+			ParentComm: comm,
+			Comm:       comm,
+			Forked:     time.Now(),
+		},
+		parent: parent,
+	}
+
+	if old, ok := c.processes[pid]; ok {
+		/*  Pid recycled.  */
+		c.link(pid, old.parent, false)
+	}
+
+	c.processes[pid] = t
+	c.link(pid, parent, true)
+	c.mutex.Unlock()
+
+	c.publish(EventFork, ProcEvent{Pid: pid, Parent: parent, Comm: comm})
+
+} /*  End of method  procConnector.forked.  */
+
+// Record an exec event.
+func (c *procConnector) execd(pid int) {
+	comm := readComm(pid)
+	parent := 0
+
+	c.mutex.Lock()
+	if t, ok := c.processes[pid]; ok {
+		t.lineage.Comm = comm
+		parent = t.parent
+	}
+	c.mutex.Unlock()
+
+	c.publish(EventExec, ProcEvent{Pid: pid, Parent: parent, Comm: comm})
+
+} /*  End of method  procConnector.execd.  */
+
+// Record an exit event, the children of the exiting process get
+// reparented (to us or some other subreaper).
+func (c *procConnector) exited(pid, parent, status, signal int) {
+	now := time.Now()
+	reparented := make([]ProcEvent, 0)
+	comm := ""
+
+	c.mutex.Lock()
+	if t, ok := c.processes[pid]; ok {
+		comm = t.lineage.Comm
+		if parent == 0 {
+			parent = t.parent
+		}
+
+		t.exited = now
+		if parent != c.reaper.pid {
+			/*  Not ours to reap, forget about it.  */
+			c.link(pid, t.parent, false)
+			delete(c.processes, pid)
+		}
+	}
+
+	for kid := range c.children[pid] {
+		t, ok := c.processes[kid]
+		if !ok || !t.exited.IsZero() {
+			continue
+		}
+
+		newParent := 0
+		if info, err := readProcess(kid); err == nil && info.PPid != pid {
+			newParent = info.PPid
+		}
+
+		t.lineage.Reparented = true
+		t.parent = newParent
+		c.link(kid, newParent, true)
+
+		reparented = append(reparented, ProcEvent{
+			Pid: kid, Parent: newParent, Comm: t.lineage.Comm,
+		})
+	}
+
+	delete(c.children, pid)
+
+	//  Every so often, prune processes that exited a while back and were
+	//  never reaped by us.
+	if now.Sub(c.pruned) > lineageRetention {
+		c.pruned = now
+		for p, t := range c.processes {
+			if !t.exited.IsZero() && now.Sub(t.exited) > lineageRetention {
+				c.link(p, t.parent, false)
+				delete(c.processes, p)
+			}
+		}
+	}
+	c.mutex.Unlock()
+
+	c.publish(EventExit, ProcEvent{Pid: pid, Parent: parent, Comm: comm,
+		ExitStatus: status, ExitSignal: signal})
+
+	for _, data := range reparented {
+		c.publish(EventReparent, data)
+	}
+
+} /*  End of method  procConnector.exited.  */
+
+// Claim the lineage of a reaped process.
+func (c *procConnector) claim(pid int) *Lineage {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	t, ok := c.processes[pid]
+	if !ok {
+		return nil
+	}
+
+	c.link(pid, t.parent, false)
+	delete(c.processes, pid)
+
+	lineage := t.lineage
+	return &lineage
+
+} /*  End of method  procConnector.claim.  */
+
+// Run the proc connector event source.
+func (c *procConnector) run() {
+	if err := c.listen(); err != nil {
+		fmt.Printf(" - Error: proc connector: %v\n", err)
+	}
+
+} /*  End of method  procConnector.run.  */
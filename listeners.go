@@ -0,0 +1,208 @@
+package reaper
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	// Socket activation env variables (ala systemd).
+	listenFdsEnv     = "LISTEN_FDS"
+	listenPidEnv     = "LISTEN_PID"
+	listenFdNamesEnv = "LISTEN_FDNAMES"
+
+	// First socket activation fd (after stdin, stdout and stderr).
+	listenFdsStart = 3
+)
+
+// Listener bound by the reaper parent and passed to the forked child
+// (socket activation). The parent keeps the listeners open across child
+// restarts, so that no connections are dropped.
+type ListenerConfig struct {
+	// Name of the listener (in LISTEN_FDNAMES), defaults to the address.
+	Name string
+
+	// Network (tcp, tcp4, tcp6 or unix) and address to listen on.
+	Network string
+	Address string
+}
+
+// Bound listener - the fd (in blocking mode as is the convention) is
+// looked up once, so that the file mode of the socket is not changed under
+// a running child.
+type boundListener struct {
+	name     string
+	listener net.Listener
+	file     *os.File
+	fd       uintptr
+}
+
+// Bind a listener.
+func bindListener(config ListenerConfig) (*boundListener, error) {
+	name := config.Name
+	if len(name) == 0 {
+		name = config.Address
+	}
+
+	if strings.HasPrefix(config.Network, "unix") {
+		/*  Stale socket from a previous run.  */
+		info, err := os.Stat(config.Address)
+		if err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(config.Address)
+		}
+	}
+
+	listener, err := net.Listen(config.Network, config.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	var file *os.File
+	switch l := listener.(type) {
+	case *net.TCPListener:
+		file, err = l.File()
+
+	case *net.UnixListener:
+		file, err = l.File()
+
+	default:
+		err = fmt.Errorf("unsupported network %q", config.Network)
+	}
+
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return &boundListener{
+		name:     name,
+		listener: listener,
+		file:     file,
+		fd:       file.Fd(),
+	}, nil
+
+} /*  End of function  bindListener.  */
+
+// Bind all the listeners.
+func bindListeners(configs []ListenerConfig) ([]*boundListener, error) {
+	bound := make([]*boundListener, 0, len(configs))
+	for _, config := range configs {
+		l, err := bindListener(config)
+		if err != nil {
+			closeListeners(bound)
+			return nil, fmt.Errorf("listen %v %v: %v", config.Network,
+				config.Address, err)
+		}
+
+		bound = append(bound, l)
+	}
+
+	return bound, nil
+
+} /*  End of function  bindListeners.  */
+
+// Close the bound listeners.
+func closeListeners(listeners []*boundListener) {
+	for _, l := range listeners {
+		l.listener.Close()
+		l.file.Close()
+	}
+
+} /*  End of function  closeListeners.  */
+
+// Pass the listeners to a child process as fds 3, 4 ... (before any other
+// extra files) along with the socket activation env variables. The child
+// sets LISTEN_PID itself (in `RunForked`) as its pid is not known yet.
+func passListeners(attrs *syscall.ProcAttr, listeners []*boundListener) {
+	if len(listeners) == 0 {
+		return
+	}
+
+	files := make([]uintptr, 0, len(attrs.Files)+len(listeners))
+	files = append(files, attrs.Files[:listenFdsStart]...)
+
+	names := make([]string, 0, len(listeners))
+	for _, l := range listeners {
+		files = append(files, l.fd)
+		names = append(names, l.name)
+	}
+
+	attrs.Files = append(files, attrs.Files[listenFdsStart:]...)
+
+	env := make([]string, 0, len(attrs.Env)+2)
+	for _, kv := range attrs.Env {
+		switch envName(kv) {
+		case listenFdsEnv, listenPidEnv, listenFdNamesEnv:
+			/*  Inherited, not for the child.  */
+		default:
+			env = append(env, kv)
+		}
+	}
+
+	attrs.Env = append(env,
+		fmt.Sprintf("%s=%d", listenFdsEnv, len(listeners)),
+		fmt.Sprintf("%s=%s", listenFdNamesEnv, strings.Join(names, ":")))
+
+} /*  End of function  passListeners.  */
+
+// Set LISTEN_PID in the forked child, if it was passed listeners.
+func activateListeners() {
+	_, fds := os.LookupEnv(listenFdsEnv)
+	_, pid := os.LookupEnv(listenPidEnv)
+	if fds && !pid {
+		os.Setenv(listenPidEnv, strconv.Itoa(os.Getpid()))
+	}
+
+} /*  End of function  activateListeners.  */
+
+/*
+ *  ======================================================================
+ *  Section: Exported functions
+ *  ======================================================================
+ */
+
+// Return the listeners passed to this (child) process via socket
+// activation, in the order of the configured listeners. The socket
+// activation env variables are unset, so they are not inherited further.
+func Listeners() ([]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv(listenPidEnv))
+	if err != nil || pid != os.Getpid() {
+		return nil, fmt.Errorf("no listeners passed to pid %d", os.Getpid())
+	}
+
+	count, err := strconv.Atoi(os.Getenv(listenFdsEnv))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", listenFdsEnv, err)
+	}
+
+	names := strings.Split(os.Getenv(listenFdNamesEnv), ":")
+
+	os.Unsetenv(listenFdsEnv)
+	os.Unsetenv(listenPidEnv)
+	os.Unsetenv(listenFdNamesEnv)
+
+	listeners := make([]net.Listener, 0, count)
+	for idx := 0; idx < count; idx++ {
+		name := fmt.Sprintf("listener-%d", idx)
+		if idx < len(names) {
+			name = names[idx]
+		}
+
+		f := os.NewFile(uintptr(listenFdsStart+idx), name)
+		l, err := net.FileListener(f)
+		f.Close()
+
+		if err != nil {
+			return listeners, fmt.Errorf("listener %v: %v", name, err)
+		}
+
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+
+} /*  End of [exported] function  Listeners.  */
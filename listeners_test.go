@@ -0,0 +1,92 @@
+package reaper
+
+import (
+	"net"
+	"reflect"
+	"runtime"
+	"syscall"
+	"testing"
+)
+
+// Listeners go before the extra files, the inherited env is replaced.
+func TestPassListeners(t *testing.T) {
+	listeners, err := bindListeners([]ListenerConfig{
+		{Name: "http", Network: "tcp", Address: "127.0.0.1:0"},
+		{Network: "tcp", Address: "127.0.0.1:0"},
+	})
+	if err != nil {
+		t.Fatalf("bind listeners: %v", err)
+	}
+
+	defer closeListeners(listeners)
+
+	attrs := &syscall.ProcAttr{
+		Files: []uintptr{0, 1, 2, 100, 101},
+		Env:   []string{"A=1", "LISTEN_FDS=9", "LISTEN_PID=1", "B=2"},
+	}
+
+	passListeners(attrs, nil)
+	if len(attrs.Files) != 5 || len(attrs.Env) != 4 {
+		t.Errorf("attrs changed without listeners: %+v", attrs)
+	}
+
+	passListeners(attrs, listeners)
+
+	files := []uintptr{0, 1, 2, listeners[0].fd, listeners[1].fd, 100, 101}
+	if !reflect.DeepEqual(attrs.Files, files) {
+		t.Errorf("files = %v, expected %v", attrs.Files, files)
+	}
+
+	env := []string{"A=1", "B=2", "LISTEN_FDS=2",
+		"LISTEN_FDNAMES=http:127.0.0.1:0"}
+	if !reflect.DeepEqual(attrs.Env, env) {
+		t.Errorf("env = %v, expected %v", attrs.Env, env)
+	}
+
+} /*  End of function  TestPassListeners.  */
+
+// Listeners are not passed to programs.
+func TestListenersWithPrograms(t *testing.T) {
+	config := Config{
+		Listeners: []ListenerConfig{
+			{Network: "tcp", Address: "127.0.0.1:0"},
+		},
+		Programs: []Program{shellProgram("app", "exit 0")},
+	}
+
+	if _, err := newSupervisor(nil, config); err == nil {
+		t.Errorf("listeners with programs, expected an error")
+	}
+
+} /*  End of function  TestListenersWithPrograms.  */
+
+// The listeners passed to the forked child stay open (across a GC) for its
+// restarts.
+func TestListenersKeptOpen(t *testing.T) {
+	config := MakeConfig()
+	config.Listeners = []ListenerConfig{
+		{Network: "tcp", Address: "127.0.0.1:0"},
+	}
+
+	s, err := newSupervisor(nil, config)
+	if err != nil {
+		t.Fatalf("new supervisor: %v", err)
+	}
+
+	defer closeListeners(s.listeners)
+
+	runtime.GC()
+	runtime.GC()
+
+	fd := int(s.programs[0].attrs.Files[listenFdsStart])
+	sa, err := syscall.Getsockname(fd)
+	if err != nil {
+		t.Fatalf("listener fd %d: %v", fd, err)
+	}
+
+	addr := s.listeners[0].listener.Addr().(*net.TCPAddr)
+	if inet, ok := sa.(*syscall.SockaddrInet4); !ok || inet.Port != addr.Port {
+		t.Errorf("listener fd %d address = %+v, expected %v", fd, sa, addr)
+	}
+
+} /*  End of function  TestListenersKeptOpen.  */
@@ -0,0 +1,222 @@
+package reaper
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Format for the captured child output.
+type OutputFormat string
+
+const (
+	OutputPrefix OutputFormat = "prefix"
+	OutputJSON   OutputFormat = "json"
+
+	// Default prefix for the captured output lines.
+	defaultOutputPrefix = "{program} | "
+
+	// Max line length, longer lines are split.
+	maxOutputLine = 64 * 1024
+
+	// How long to wait for the captured output to drain on exit - the
+	// pipes stay open while any (daemonized) descendants hold them.
+	outputDrainTimeout = time.Second
+)
+
+// Output capture configuration for the forked child and the supervised
+// programs - their stdout and stderr are piped through the parent, which
+// line buffers them and writes them (prefixed or as json) to the writer.
+type OutputConfig struct {
+	// Capture the stdout and stderr of the child processes.
+	Capture bool
+
+	// Output format, defaults to prefixed lines.
+	Format OutputFormat
+
+	// Line prefix - `{program}` and `{stream}` are replaced with the
+	// program name and the stream (stdout or stderr). Defaults to
+	// "{program} | ".
+	Prefix string
+
+	// Destination for the output, defaults to the parent's stdout.
+	Writer io.Writer
+}
+
+// Captured output line in the json format.
+type OutputLine struct {
+	Stream    string    `json:"stream"`
+	Timestamp time.Time `json:"timestamp"`
+	Program   string    `json:"program"`
+	Line      string    `json:"line"`
+}
+
+// Output multiplexer for the captured child output. Without capture, it
+// only passes through stderr to keep the tail (last lines) of it.
+type outputMux struct {
+	config   OutputConfig
+	mutex    sync.Mutex
+	writer   io.Writer
+	readers  sync.WaitGroup
+	tailSize int
+	tails    map[string][]string
+}
+
+// Make a new output multiplexer, keeping the last tail size stderr lines
+// of each program.
+func newOutputMux(config OutputConfig, tailSize int) *outputMux {
+	m := &outputMux{
+		config:   config,
+		writer:   config.Writer,
+		tailSize: tailSize,
+		tails:    make(map[string][]string),
+	}
+
+	if !config.Capture {
+		m.writer = os.Stderr
+	}
+
+	if m.writer == nil {
+		m.writer = os.Stdout
+	}
+
+	if len(m.config.Prefix) == 0 {
+		m.config.Prefix = defaultOutputPrefix
+	}
+
+	return m
+
+} /*  End of function  newOutputMux.  */
+
+// Format a captured output line.
+func (m *outputMux) format(program, stream, line string) []byte {
+	if !m.config.Capture {
+		return []byte(line + "\n")
+	}
+
+	if m.config.Format == OutputJSON {
+		data, err := json.Marshal(OutputLine{
+			Stream:    stream,
+			Timestamp: time.Now(),
+			Program:   program,
+			Line:      line,
+		})
+
+		if err == nil {
+			return append(data, '\n')
+		}
+	}
+
+	replacer := strings.NewReplacer("{program}", program, "{stream}", stream)
+	return []byte(replacer.Replace(m.config.Prefix) + line + "\n")
+
+} /*  End of method  outputMux.format.  */
+
+// Write a line to the destination - one line at a time, so that the
+// output of the different programs doesn't get interleaved mid-line.
+func (m *outputMux) write(program, stream, line string) {
+	data := m.format(program, stream, line)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.writer.Write(data)
+
+	if stream == "stderr" && m.tailSize > 0 {
+		tail := append(m.tails[program], line)
+		if len(tail) > m.tailSize {
+			tail = tail[len(tail)-m.tailSize:]
+		}
+
+		m.tails[program] = tail
+	}
+
+} /*  End of method  outputMux.write.  */
+
+// Return the last stderr lines of a program.
+func (m *outputMux) tail(program string) []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return append([]string{}, m.tails[program]...)
+
+} /*  End of method  outputMux.tail.  */
+
+// Read lines from a captured stream until EOF.
+func (m *outputMux) relay(program, stream string, pipe *os.File) {
+	defer m.readers.Done()
+	defer pipe.Close()
+
+	reader := bufio.NewReaderSize(pipe, maxOutputLine)
+	for {
+		data, err := reader.ReadSlice('\n')
+		if len(data) > 0 {
+			line := strings.TrimRight(string(data), "\r\n")
+			m.write(program, stream, line)
+		}
+
+		if err != nil && err != bufio.ErrBufferFull {
+			return
+		}
+	}
+
+} /*  End of method  outputMux.relay.  */
+
+// Capture the stdout and stderr (fds 1 and 2) of a child process - only
+// stderr if the output is not captured, but its tail is needed. Returns
+// the files for the child and the child's ends of the pipes, which the
+// caller closes once the child is started.
+func (m *outputMux) capture(program string,
+	files []uintptr) ([]uintptr, []*os.File, error) {
+	captured := append([]uintptr{}, files...)
+	ends := make([]*os.File, 0, 2)
+
+	for fd, stream := range []string{"stdout", "stderr"} {
+		fd++
+		if fd >= len(captured) {
+			break
+		}
+
+		if !m.config.Capture && stream != "stderr" {
+			continue
+		}
+
+		r, w, err := os.Pipe()
+		if err != nil {
+			for _, f := range ends {
+				f.Close()
+			}
+
+			return files, nil, fmt.Errorf("%v pipe: %v", stream, err)
+		}
+
+		m.readers.Add(1)
+		go m.relay(program, stream, r)
+
+		captured[fd] = w.Fd()
+		ends = append(ends, w)
+	}
+
+	return captured, ends, nil
+
+} /*  End of method  outputMux.capture.  */
+
+// Wait for the captured output to drain.
+func (m *outputMux) drain(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		m.readers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+
+} /*  End of method  outputMux.drain.  */
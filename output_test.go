@@ -0,0 +1,116 @@
+package reaper
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Prefixed and json output lines.
+func TestOutputFormat(t *testing.T) {
+	var out bytes.Buffer
+
+	m := newOutputMux(OutputConfig{Capture: true, Writer: &out}, 0)
+	m.write("app", "stdout", "hello")
+
+	m.config.Prefix = "[{program}:{stream}] "
+	m.write("db", "stderr", "oops")
+
+	expected := "app | hello\n[db:stderr] oops\n"
+	if out.String() != expected {
+		t.Errorf("output = %q, expected %q", out.String(), expected)
+	}
+
+	out.Reset()
+	m.config.Format = OutputJSON
+	m.write("app", "stdout", "hello")
+
+	var line OutputLine
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("json output %q: %v", out.String(), err)
+	}
+
+	if line.Program != "app" || line.Stream != "stdout" ||
+		line.Line != "hello" || line.Timestamp.IsZero() {
+		t.Errorf("json line = %+v", line)
+	}
+
+} /*  End of function  TestOutputFormat.  */
+
+// Only the last stderr lines are kept in the tail.
+func TestOutputTail(t *testing.T) {
+	var out bytes.Buffer
+
+	m := newOutputMux(OutputConfig{Capture: true, Writer: &out}, 2)
+	for _, line := range []string{"one", "two", "three"} {
+		m.write("app", "stderr", line)
+		m.write("app", "stdout", "out-"+line)
+	}
+
+	expected := []string{"two", "three"}
+	if tail := m.tail("app"); !reflect.DeepEqual(tail, expected) {
+		t.Errorf("tail = %v, expected %v", tail, expected)
+	}
+
+	if tail := m.tail("db"); len(tail) != 0 {
+		t.Errorf("tail of an unknown program = %v", tail)
+	}
+
+} /*  End of function  TestOutputTail.  */
+
+// Relay lines from the captured pipes - long lines are split.
+func TestOutputRelay(t *testing.T) {
+	var out bytes.Buffer
+
+	m := newOutputMux(OutputConfig{Capture: true, Writer: &out,
+		Prefix: "{stream}: "}, 0)
+
+	files, ends, err := m.capture("app", []uintptr{0, 1, 2})
+	if err != nil || len(ends) != 2 || files[1] == 1 || files[2] == 2 {
+		t.Fatalf("capture = %v, %v, %v", files, ends, err)
+	}
+
+	long := strings.Repeat("x", maxOutputLine+10)
+	ends[0].WriteString("hello\r\nworld\n" + long + "\n")
+	ends[1].WriteString("oops")
+	for _, f := range ends {
+		f.Close()
+	}
+
+	m.drain(5 * time.Second)
+
+	output := out.String()
+	for _, line := range []string{"stdout: hello\n", "stdout: world\n",
+		"stderr: oops\n", "stdout: xxxxxxxxxx\n"} {
+		if !strings.Contains(output, line) {
+			t.Errorf("output is missing %q", line)
+		}
+	}
+
+} /*  End of function  TestOutputRelay.  */
+
+// Without capture, only stderr is passed through (for its tail).
+func TestOutputStderrOnly(t *testing.T) {
+	m := newOutputMux(OutputConfig{}, 2)
+	if m.writer != os.Stderr {
+		t.Errorf("writer is not stderr without capture")
+	}
+
+	files, ends, err := m.capture("app", []uintptr{0, 1, 2})
+	if err != nil || len(ends) != 1 || files[1] != 1 || files[2] == 2 {
+		t.Fatalf("capture = %v, %v, %v", files, ends, err)
+	}
+
+	ends[0].WriteString("stderr line\n")
+	ends[0].Close()
+	m.drain(5 * time.Second)
+
+	if tail := m.tail("app"); len(tail) != 1 || tail[0] != "stderr line" {
+		t.Errorf("tail = %v", tail)
+	}
+
+} /*  End of function  TestOutputStderrOnly.  */
@@ -0,0 +1,63 @@
+package reaper
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Pid 1 signal handling configuration (for the in-process `Reap`/`Start`
+// mode). The kernel doesn't deliver a signal to pid 1 unless it has a
+// handler for it, so a `docker stop` (SIGTERM) otherwise hangs until the
+// SIGKILL. With this enabled, the reaper handles the shutdown signals by
+// calling the shutdown hook or else exiting with 128+signal.
+// Not used in forked mode (`RunForked`), the parent forwards the signals
+// to the child there.
+type Pid1SignalsConfig struct {
+	// Enable the pid 1 signal handlers.
+	Enable bool
+
+	// Signals to handle, defaults to SIGTERM and SIGINT.
+	Signals []syscall.Signal
+
+	// Shutdown hook called (in its own goroutine) for a signal instead of
+	// exiting - the hook is then responsible for exiting the program.
+	OnSignal func(sig syscall.Signal)
+}
+
+// Default signals handled for pid 1.
+var defaultPid1Signals = []syscall.Signal{syscall.SIGTERM, syscall.SIGINT}
+
+// Install the pid 1 signal handlers.
+func (r *Reaper) handlePid1Signals() {
+	config := r.config.Pid1Signals
+
+	signals := config.Signals
+	if len(signals) == 0 {
+		signals = defaultPid1Signals
+	}
+
+	sigs := make(chan os.Signal, len(signals))
+	for _, sig := range signals {
+		signal.Notify(sigs, sig)
+	}
+
+	go func() {
+		for sig := range sigs {
+			sysSig, _ := sig.(syscall.Signal)
+			if r.config.Debug {
+				fmt.Printf(" - Reaper received %v as pid %d\n", sig,
+					os.Getpid())
+			}
+
+			if config.OnSignal != nil {
+				go config.OnSignal(sysSig)
+				continue
+			}
+
+			os.Exit(128 + int(sysSig))
+		}
+	}()
+
+} /*  End of method  Reaper.handlePid1Signals.  */
@@ -0,0 +1,66 @@
+package reaper
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// The shutdown hook is called for the handled signals.
+func TestPid1SignalsHook(t *testing.T) {
+	received := make(chan syscall.Signal, 1)
+
+	r := &Reaper{}
+	r.config.Pid1Signals = Pid1SignalsConfig{
+		Enable:   true,
+		Signals:  []syscall.Signal{syscall.SIGUSR1},
+		OnSignal: func(sig syscall.Signal) { received <- sig },
+	}
+
+	r.handlePid1Signals()
+	defer signal.Reset(syscall.SIGUSR1)
+
+	syscall.Kill(os.Getpid(), syscall.SIGUSR1)
+
+	select {
+	case sig := <-received:
+		if sig != syscall.SIGUSR1 {
+			t.Errorf("hook got %v, expected SIGUSR1", sig)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Errorf("shutdown hook was not called")
+	}
+
+} /*  End of function  TestPid1SignalsHook.  */
+
+// Without a hook, the process exits with 128+signal.
+func TestPid1SignalsExit(t *testing.T) {
+	for _, sig := range []syscall.Signal{syscall.SIGTERM, syscall.SIGINT} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+		cmd.Env = append(os.Environ(), helperProcessEnv+"=pid1signals")
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			t.Fatalf("helper stdout: %v", err)
+		}
+
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("start helper process: %v", err)
+		}
+
+		bufio.NewReader(stdout).ReadString('\n')
+		cmd.Process.Signal(sig)
+
+		err = cmd.Wait()
+		expected := 128 + int(sig)
+		if code := cmd.ProcessState.ExitCode(); code != expected {
+			t.Errorf("%v: exit code = %d (%v), expected %d", sig, code,
+				err, expected)
+		}
+	}
+
+} /*  End of function  TestPid1SignalsExit.  */
@@ -0,0 +1,271 @@
+package reaper
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Cgroup v2 unified hierarchy mount point.
+	cgroupRoot = "/sys/fs/cgroup"
+
+	// Pid budget sources.
+	PidSourceCgroup = "cgroup"
+	PidSourceKernel = "kernel"
+)
+
+// Pid budget monitor configuration. Usage is sampled from the cgroup v2
+// `pids.current` and `pids.max` files or if those are not available, from
+// the kernel `pid_max` and the number of tasks (threads) on the system -
+// both limits apply to tasks and not just processes.
+type PidBudgetConfig struct {
+	// Sampling interval, the monitor is disabled if this is zero.
+	Interval time.Duration
+
+	// Usage ratio (0.0 - 1.0) at which a warning event is published.
+	WarnThreshold float64
+
+	// Usage ratio (0.0 - 1.0) at which a high-water event is published.
+	HighWater float64
+
+	// Trigger an immediate reap sweep and zombie scan at high-water.
+	SweepOnHighWater bool
+}
+
+// Pid usage sample.
+type PidUsage struct {
+	Current int64     `json:"current"`
+	Max     int64     `json:"max"`
+	Ratio   float64   `json:"ratio"`
+	Source  string    `json:"source"`
+	Time    time.Time `json:"time"`
+}
+
+// Event data for the pid budget events.
+type PidBudgetAlert struct {
+	Usage     PidUsage `json:"usage"`
+	Threshold float64  `json:"threshold"`
+	Zombies   int      `json:"zombies"`
+}
+
+// Pid budget monitor.
+type pidBudget struct {
+	config PidBudgetConfig
+	reaper *Reaper
+	level  EventType
+}
+
+// Read a single integer value from a file - "max" means unlimited.
+func readPidCount(path string) (int64, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return math.MaxInt64, nil
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+
+} /*  End of function  readPidCount.  */
+
+// Parse the number of tasks (threads) from the contents of /proc/loadavg -
+// the 4th field is "runnable/total".
+func parseLoadavgTasks(data string) (int64, error) {
+	fields := strings.Fields(data)
+	if len(fields) < 4 {
+		return 0, fmt.Errorf("malformed loadavg %q", data)
+	}
+
+	idx := strings.IndexByte(fields[3], '/')
+	if idx < 0 {
+		return 0, fmt.Errorf("malformed loadavg tasks %q", fields[3])
+	}
+
+	return strconv.ParseInt(fields[3][idx+1:], 10, 64)
+
+} /*  End of function  parseLoadavgTasks.  */
+
+// Return the number of tasks (threads) on the system, from /proc/loadavg
+// or else by counting the /proc/<pid>/task entries.
+func countTasks() (int64, error) {
+	data, err := ioutil.ReadFile(filepath.Join(procRoot, "loadavg"))
+	if err == nil {
+		if tasks, err := parseLoadavgTasks(string(data)); err == nil {
+			return tasks, nil
+		}
+	}
+
+	procs, err := listProcesses()
+	if err != nil {
+		return 0, err
+	}
+
+	tasks := int64(0)
+	for _, p := range procs {
+		dir := filepath.Join(procRoot, strconv.Itoa(p.Pid), "task")
+		if entries, err := ioutil.ReadDir(dir); err == nil {
+			tasks += int64(len(entries))
+		}
+	}
+
+	return tasks, nil
+
+} /*  End of function  countTasks.  */
+
+// Return the cgroup v2 directory for this process.
+func cgroupDir() (string, error) {
+	f, err := os.Open(filepath.Join(procRoot, "self", "cgroup"))
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close()
+
+	//  cgroup v2 has a single "0::/path" entry.
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "0::") {
+			return filepath.Join(cgroupRoot, line[3:]), nil
+		}
+	}
+
+	return "", fmt.Errorf("no cgroup v2 entry")
+
+} /*  End of function  cgroupDir.  */
+
+// Sample the current pid usage.
+func samplePidUsage() (PidUsage, error) {
+	usage := PidUsage{Time: time.Now()}
+
+	//  Prefer the cgroup v2 pid controller - the kernel pid_max is used
+	//  as the limit if the cgroup is unlimited.
+	path := filepath.Join(procRoot, "sys", "kernel", "pid_max")
+	pidMax, err := readPidCount(path)
+	if err != nil {
+		pidMax = math.MaxInt64
+	}
+
+	if dir, err := cgroupDir(); err == nil {
+		current, err := readPidCount(filepath.Join(dir, "pids.current"))
+		if err == nil {
+			limit, err := readPidCount(filepath.Join(dir, "pids.max"))
+			if err == nil {
+				if limit > pidMax {
+					limit = pidMax
+				}
+
+				usage.Current = current
+				usage.Max = limit
+				usage.Source = PidSourceCgroup
+			}
+		}
+	}
+
+	if len(usage.Source) == 0 {
+		tasks, err := countTasks()
+		if err != nil {
+			return usage, err
+		}
+
+		usage.Current = tasks
+		usage.Max = pidMax
+		usage.Source = PidSourceKernel
+	}
+
+	if usage.Max > 0 && usage.Max < math.MaxInt64 {
+		usage.Ratio = float64(usage.Current) / float64(usage.Max)
+	}
+
+	return usage, nil
+
+} /*  End of function  samplePidUsage.  */
+
+// Make a new pid budget monitor for the reaper.
+func newPidBudget(r *Reaper) *pidBudget {
+	return &pidBudget{config: r.config.PidBudget, reaper: r}
+
+} /*  End of function  newPidBudget.  */
+
+// Sample pid usage and publish events when crossing the thresholds.
+func (b *pidBudget) check() {
+	r := b.reaper
+
+	usage, err := samplePidUsage()
+	if err != nil {
+		if r.config.Debug {
+			fmt.Printf(" - Pid budget sample error: %v\n", err)
+		}
+		return
+	}
+
+	r.mutex.Lock()
+	r.stats.PidUsage = usage
+	r.mutex.Unlock()
+
+	level := EventType("")
+	threshold := 0.0
+	switch {
+	case b.config.HighWater > 0 && usage.Ratio >= b.config.HighWater:
+		level, threshold = EventPidBudgetHighWater, b.config.HighWater
+	case b.config.WarnThreshold > 0 && usage.Ratio >= b.config.WarnThreshold:
+		level, threshold = EventPidBudgetWarning, b.config.WarnThreshold
+	}
+
+	//  Only publish events on a level change.
+	if level == b.level {
+		return
+	}
+
+	b.level = level
+	if len(level) == 0 {
+		level = EventPidBudgetRecovered
+	}
+
+	alert := PidBudgetAlert{Usage: usage, Threshold: threshold, Zombies: -1}
+	if level == EventPidBudgetHighWater && b.config.SweepOnHighWater {
+		r.sweep()
+
+		if r.watchdog != nil {
+			r.watchdog.scan()
+		}
+
+		if zombies, err := ScanZombies(); err == nil {
+			alert.Zombies = len(zombies)
+		}
+	}
+
+	if r.config.Debug {
+		fmt.Printf(" - Pid budget %s: %d/%d (%.2f%%) [%s]\n", level,
+			usage.Current, usage.Max, usage.Ratio*100, usage.Source)
+	}
+
+	event := makeEvent(level, os.Getpid(), alert,
+		"pid usage %d/%d (%.2f%%) [%s]", usage.Current, usage.Max,
+		usage.Ratio*100, usage.Source)
+
+	publish(r.config.EventChannel, event)
+
+} /*  End of method  pidBudget.check.  */
+
+// Run the pid budget monitor periodically.
+func (b *pidBudget) run() {
+	ticker := time.NewTicker(b.config.Interval)
+	defer ticker.Stop()
+
+	b.check()
+	for range ticker.C {
+		b.check()
+	}
+
+} /*  End of method  pidBudget.run.  */
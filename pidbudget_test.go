@@ -0,0 +1,71 @@
+package reaper
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Parse the task count from /proc/loadavg.
+func TestParseLoadavgTasks(t *testing.T) {
+	tasks, err := parseLoadavgTasks("0.42 0.24 0.12 3/1234 5678\n")
+	if err != nil || tasks != 1234 {
+		t.Errorf("tasks = %d, %v, expected 1234", tasks, err)
+	}
+
+	for _, data := range []string{"", "0.42 0.24 0.12", "0.1 0.2 0.3 42"} {
+		if _, err := parseLoadavgTasks(data); err == nil {
+			t.Errorf("loadavg %q: expected an error", data)
+		}
+	}
+
+} /*  End of function  TestParseLoadavgTasks.  */
+
+// The task count includes threads - there is at least one per process.
+func TestCountTasks(t *testing.T) {
+	if _, err := os.Stat(procRoot); err != nil {
+		t.Skip("no /proc filesystem")
+	}
+
+	procs, err := listProcesses()
+	if err != nil {
+		t.Fatalf("list processes: %v", err)
+	}
+
+	tasks, err := countTasks()
+	if err != nil {
+		t.Fatalf("count tasks: %v", err)
+	}
+
+	//  Allow for some processes exiting in between.
+	if tasks < int64(len(procs))/2 {
+		t.Errorf("tasks = %d, processes = %d", tasks, len(procs))
+	}
+
+} /*  End of function  TestCountTasks.  */
+
+// Read pid counts, "max" is unlimited.
+func TestReadPidCount(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reaper-pids")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	tests := map[string]int64{"42\n": 42, "max\n": math.MaxInt64}
+	for data, expected := range tests {
+		path := filepath.Join(dir, "pids")
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatalf("write %v: %v", path, err)
+		}
+
+		if count, err := readPidCount(path); err != nil || count != expected {
+			t.Errorf("%q: count = %d, %v, expected %d", data, count, err,
+				expected)
+		}
+	}
+
+} /*  End of function  TestReadPidCount.  */
@@ -0,0 +1,249 @@
+package reaper
+
+/*  Note:  This is a linux only implementation [reads from /proc].  */
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Mount point of the proc filesystem.
+	procRoot = "/proc"
+
+	// Clock ticks per second (USER_HZ) used for the process start time
+	// in /proc/<pid>/stat - this is 100 on all the supported platforms.
+	clockTicks = 100
+
+	// Fixed inode numbers of the initial pid and user namespaces (from
+	// linux/proc_ns.h).
+	procPidInitIno  = 0xEFFFFFFC
+	procUserInitIno = 0xEFFFFFFD
+)
+
+// Process information read from /proc/<pid>/stat.
+type ProcessInfo struct {
+	Pid       int       `json:"pid"`
+	PPid      int       `json:"ppid"`
+	Pgid      int       `json:"pgid"`
+	Sid       int       `json:"sid"`
+	State     string    `json:"state"`
+	Comm      string    `json:"comm"`
+	StartTime time.Time `json:"startTime"`
+
+	//  Start time in clock ticks since boot, pid + start ticks uniquely
+	//  identify a process (pids get recycled).
+	startTicks uint64
+}
+
+var bootTime struct {
+	once sync.Once
+	time time.Time
+}
+
+// Return the system boot time. Derived from /proc/uptime rather than the
+// btime in /proc/stat as the latter only has a resolution of a second.
+func systemBootTime() time.Time {
+	bootTime.once.Do(func() {
+		data, err := ioutil.ReadFile(filepath.Join(procRoot, "uptime"))
+		if err != nil {
+			return
+		}
+
+		fields := strings.Fields(string(data))
+		if len(fields) == 0 {
+			return
+		}
+
+		uptime, err := strconv.ParseFloat(fields[0], 64)
+		if err == nil {
+			elapsed := time.Duration(uptime * float64(time.Second))
+			bootTime.time = time.Now().Add(-elapsed)
+		}
+	})
+
+	return bootTime.time
+
+} /*  End of function  systemBootTime.  */
+
+// Parse the contents of a /proc/<pid>/stat file.
+func parseProcStat(data string) (ProcessInfo, error) {
+	var info ProcessInfo
+
+	//  The command name is enclosed in parens and can itself contain
+	//  spaces and parens, so use the last closing paren as the marker.
+	start := strings.IndexByte(data, '(')
+	end := strings.LastIndexByte(data, ')')
+	if start < 0 || end < start {
+		return info, fmt.Errorf("malformed stat %q", data)
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(data[:start]))
+	if err != nil {
+		return info, fmt.Errorf("malformed stat pid: %v", err)
+	}
+
+	info.Pid = pid
+	info.Comm = data[start+1 : end]
+
+	//  Fields after the command name, starting at field 3 (state).
+	fields := strings.Fields(data[end+1:])
+	if len(fields) < 20 {
+		return info, fmt.Errorf("short stat for pid %d", pid)
+	}
+
+	info.State = fields[0]
+	ints := make([]int, 3)
+	for idx := range ints {
+		ints[idx], err = strconv.Atoi(fields[idx+1])
+		if err != nil {
+			return info, fmt.Errorf("malformed stat field: %v", err)
+		}
+	}
+
+	info.PPid, info.Pgid, info.Sid = ints[0], ints[1], ints[2]
+
+	//  Field 22 (starttime) - in clock ticks since boot.
+	info.startTicks, err = strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return info, fmt.Errorf("malformed stat starttime: %v", err)
+	}
+
+	if boot := systemBootTime(); !boot.IsZero() {
+		offset := time.Duration(info.startTicks) * time.Second / clockTicks
+		info.StartTime = boot.Add(offset)
+	}
+
+	return info, nil
+
+} /*  End of function  parseProcStat.  */
+
+// Read process information for a specific pid.
+func readProcess(pid int) (ProcessInfo, error) {
+	path := filepath.Join(procRoot, strconv.Itoa(pid), "stat")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ProcessInfo{}, err
+	}
+
+	return parseProcStat(string(data))
+
+} /*  End of function  readProcess.  */
+
+// List all the processes visible in /proc. Processes that exit while
+// the list is being built are silently skipped.
+func listProcesses() ([]ProcessInfo, error) {
+	entries, err := ioutil.ReadDir(procRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	procs := make([]ProcessInfo, 0, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+
+		if info, err := readProcess(pid); err == nil {
+			procs = append(procs, info)
+		}
+	}
+
+	return procs, nil
+
+} /*  End of function  listProcesses.  */
+
+// Parse the pid namespace depth from the contents of /proc/<pid>/status -
+// the NSpid line has the pid in each nested pid namespace. Returns 0 if
+// the kernel doesn't report it.
+func parseNSpidDepth(status string) int {
+	for _, line := range strings.Split(status, "\n") {
+		if strings.HasPrefix(line, "NSpid:") {
+			return len(strings.Fields(line[len("NSpid:"):]))
+		}
+	}
+
+	return 0
+
+} /*  End of function  parseNSpidDepth.  */
+
+// Parse the contents of /proc/<pid>/uid_map (or gid_map) and check if it
+// is the identity mapping of the initial user namespace.
+func isInitialIdMap(data string) bool {
+	fields := strings.Fields(data)
+	return len(fields) == 3 && fields[0] == "0" && fields[1] == "0" &&
+		fields[2] == "4294967295"
+
+} /*  End of function  isInitialIdMap.  */
+
+// Check if a namespace link (/proc/<pid>/ns/<type>) is the initial one.
+func isInitialNamespace(link string, ino uint64) bool {
+	target, err := os.Readlink(link)
+	if err != nil {
+		return true /*  Can't tell, assume it is.  */
+	}
+
+	return target == fmt.Sprintf("%s:[%d]", filepath.Base(link), ino)
+
+} /*  End of function  isInitialNamespace.  */
+
+// Check if we run in the initial pid and user namespaces. The NSpid depth
+// only covers the namespaces visible from the mounted /proc, so check the
+// namespace inodes as well.
+func inInitialNamespaces() error {
+	self := filepath.Join(procRoot, "self")
+
+	data, err := ioutil.ReadFile(filepath.Join(self, "status"))
+	if err != nil {
+		return err
+	}
+
+	if depth := parseNSpidDepth(string(data)); depth > 1 {
+		return fmt.Errorf("in a nested pid namespace (depth %d)", depth)
+	}
+
+	if !isInitialNamespace(filepath.Join(self, "ns", "pid"), procPidInitIno) {
+		return fmt.Errorf("in a non-initial pid namespace")
+	}
+
+	userNs := filepath.Join(self, "ns", "user")
+	data, err = ioutil.ReadFile(filepath.Join(self, "uid_map"))
+	if !isInitialNamespace(userNs, procUserInitIno) ||
+		(err == nil && !isInitialIdMap(string(data))) {
+		return fmt.Errorf("in a non-initial user namespace")
+	}
+
+	return nil
+
+} /*  End of function  inInitialNamespaces.  */
+
+/*
+ *  ======================================================================
+ *  Section: Exported functions
+ *  ======================================================================
+ */
+
+// Scan /proc for zombie processes (state Z) in the pid namespace.
+func ScanZombies() ([]ProcessInfo, error) {
+	procs, err := listProcesses()
+	if err != nil {
+		return nil, err
+	}
+
+	zombies := make([]ProcessInfo, 0)
+	for _, p := range procs {
+		if p.State == "Z" {
+			zombies = append(zombies, p)
+		}
+	}
+
+	return zombies, nil
+
+} /*  End of [exported] function  ScanZombies.  */
@@ -0,0 +1,90 @@
+package reaper
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Make a /proc/<pid>/stat line.
+func statLine(pid int, comm, state string, ppid, pgid, sid int) string {
+	//  Fields 7 - 21 (tty_nr ... itrealvalue) and 22 (starttime).
+	rest := strings.Repeat("0 ", 15) + "4242 0 0"
+
+	return fmt.Sprintf("%d (%s) %s %d %d %d %s\n", pid, comm, state, ppid,
+		pgid, sid, rest)
+
+} /*  End of function  statLine.  */
+
+// Parse /proc/<pid>/stat lines, including command names with parens.
+func TestParseProcStat(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		comm  string
+		state string
+		fail  bool
+	}{
+		{"simple", statLine(42, "sleep", "S", 1, 42, 42), "sleep", "S",
+			false},
+		{"spaces", statLine(42, "my app", "R", 1, 42, 42), "my app", "R",
+			false},
+		{"parens", statLine(42, "a) S 7 (b", "Z", 1, 42, 42), "a) S 7 (b",
+			"Z", false},
+		{"no parens", "42 sleep S 1 42 42", "", "", true},
+		{"bad pid", "x (sleep) S 1 42 42", "", "", true},
+		{"short", "42 (sleep) S 1 42 42", "", "", true},
+	}
+
+	for _, tt := range tests {
+		info, err := parseProcStat(tt.data)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %+v", tt.name, info)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+
+		if info.Pid != 42 || info.Comm != tt.comm || info.State != tt.state ||
+			info.PPid != 1 ||
+			info.Pgid != 42 || info.Sid != 42 || info.startTicks != 4242 {
+			t.Errorf("%s: parsed %+v", tt.name, info)
+		}
+	}
+
+} /*  End of function  TestParseProcStat.  */
+
+// Parse the pid namespace depth and check the user namespace id map.
+func TestNamespaceChecks(t *testing.T) {
+	depths := map[string]int{
+		"Name:\tsleep\nNSpid:\t42\nNSpgid:\t42\n":         1,
+		"Name:\tsleep\nNSpid:\t4242\t42\t1\nNSpgid:\t1\n": 3,
+		"Name:\tsleep\nPid:\t42\n":                        0,
+	}
+
+	for status, expected := range depths {
+		if depth := parseNSpidDepth(status); depth != expected {
+			t.Errorf("%q: depth = %d, expected %d", status, depth,
+				expected)
+		}
+	}
+
+	maps := map[string]bool{
+		"         0          0 4294967295\n": true,
+		"         0     100000      65536\n": false,
+		"      1000       1000          1\n": false,
+	}
+
+	for data, expected := range maps {
+		if initial := isInitialIdMap(data); initial != expected {
+			t.Errorf("%q: initial = %v, expected %v", data, initial,
+				expected)
+		}
+	}
+
+} /*  End of function  TestNamespaceChecks.  */
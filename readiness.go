@@ -0,0 +1,165 @@
+package reaper
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// Env variable with the readiness pipe file descriptor number passed
+	// to the child process.
+	ReadyFdEnv = "REAPER_READY_FD"
+
+	// Default interval for polling the file/socket readiness checks.
+	defaultReadyInterval = 100 * time.Millisecond
+)
+
+// Readiness check for a supervised program - the programs that depend on
+// it are only started once it is ready. All the specified checks need to
+// pass and a program without any checks is ready as soon as it starts.
+type ReadinessCheck struct {
+	// Ready once this file exists.
+	File string
+
+	// Ready once this unix socket accepts connections.
+	Socket string
+
+	// Ready once the program writes to its readiness pipe, whose file
+	// descriptor number is passed in the `REAPER_READY_FD` env variable.
+	// See `NotifyReady`.
+	Pipe bool
+
+	// Polling interval for the file and socket checks (default 100ms).
+	Interval time.Duration
+
+	// Max time to wait for the program to become ready (0 = forever).
+	// A program that is not ready in time is stopped.
+	Timeout time.Duration
+}
+
+// Readiness of a program (start).
+type readiness struct {
+	program *program
+	gen     int
+	ready   bool
+}
+
+// Check if any readiness checks are configured.
+func (rc ReadinessCheck) enabled() bool {
+	return len(rc.File) > 0 || len(rc.Socket) > 0 || rc.Pipe
+
+} /*  End of method  ReadinessCheck.enabled.  */
+
+// Check if the readiness file exists.
+func fileReady(path string) bool {
+	if len(path) == 0 {
+		return true
+	}
+
+	_, err := os.Stat(path)
+	return err == nil
+
+} /*  End of function  fileReady.  */
+
+// Check if the readiness socket is accepting connections.
+func socketReady(path string, timeout time.Duration) bool {
+	if len(path) == 0 {
+		return true
+	}
+
+	conn, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return false
+	}
+
+	conn.Close()
+	return true
+
+} /*  End of function  socketReady.  */
+
+// Wait for a program to become ready. The pipe (if any) is the read end
+// of the readiness pipe and quit is closed when the program exits.
+func waitReady(rc ReadinessCheck, pipe *os.File, quit chan struct{}) bool {
+	interval := rc.Interval
+	if interval <= 0 {
+		interval = defaultReadyInterval
+	}
+
+	var timeout <-chan time.Time
+	if rc.Timeout > 0 {
+		timer := time.NewTimer(rc.Timeout)
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	piped := make(chan bool, 1)
+	if pipe != nil {
+		go func() {
+			/*  EOF => the program exited without being ready.  */
+			buf := make([]byte, 1)
+			n, _ := pipe.Read(buf)
+			pipe.Close()
+
+			piped <- n > 0
+		}()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	pipeReady := pipe == nil
+	for {
+		if pipeReady && fileReady(rc.File) && socketReady(rc.Socket, interval) {
+			return true
+		}
+
+		select {
+		case ok := <-piped:
+			if !ok {
+				return false
+			}
+
+			pipeReady = true
+
+		case <-ticker.C: /*  poll again.  */
+
+		case <-timeout:
+			return false
+
+		case <-quit:
+			return false
+		}
+	}
+
+} /*  End of function  waitReady.  */
+
+/*
+ *  ======================================================================
+ *  Section: Exported functions
+ *  ======================================================================
+ */
+
+// Notify the reaper parent that this (child) program is ready, using the
+// readiness pipe passed in the `REAPER_READY_FD` env variable.
+func NotifyReady() error {
+	value, ok := os.LookupEnv(ReadyFdEnv)
+	if !ok {
+		return fmt.Errorf("no readiness pipe (%s not set)", ReadyFdEnv)
+	}
+
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s=%q: %v", ReadyFdEnv, value, err)
+	}
+
+	pipe := os.NewFile(uintptr(fd), "reaper-ready")
+	defer pipe.Close()
+
+	_, err = pipe.Write([]byte("1"))
+	return err
+
+} /*  End of [exported] function  NotifyReady.  */
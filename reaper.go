@@ -8,7 +8,9 @@ import (
 	"os/signal"
 	"regexp"
 	"runtime"
+	"sync"
 	"syscall"
+	"time"
 )
 
 const (
@@ -27,6 +29,101 @@ type Config struct {
 	CloneEnvIndicator    string
 	DisableCallerCheck   bool
 	Debug                bool
+
+	// Optional channel for reaper events (watchdog etc) - use a
+	// `buffered` channel, events are dropped if the channel is full.
+	EventChannel chan Event
+
+	// Negligent parent watchdog, disabled if the interval is zero.
+	Watchdog WatchdogConfig
+
+	// Pid budget monitor, disabled if the interval is zero.
+	PidBudget PidBudgetConfig
+
+	// Proc connector (netlink) fork/exec/exit event source.
+	ProcConnector ProcConnectorConfig
+
+	// Process attributes for the forked child (`RunForked`/`WithReaper`).
+	Child ChildConfig
+
+	// Restart policy for the forked child (`RunForked`/`WithReaper`).
+	Restart RestartConfig
+
+	// Exit the parent with the forked child's (or the main program's)
+	// exit code - by default the parent exits with 0 (as it always has),
+	// unless the child sets an exit code over the control channel.
+	PropagateExitCode bool
+
+	// Programs to run and supervise in forked mode instead of forking a
+	// child (re-exec) of ourselves.
+	Programs []Program
+
+	// Capture the stdout and stderr of the forked child (or programs).
+	Output OutputConfig
+
+	// Termination report written when the forked child (or programs) exit.
+	Termination TerminationConfig
+
+	// Systemd notify (sd_notify) support for the forked child.
+	SystemdNotify SystemdNotifyConfig
+
+	// Control channel between the forked child (or programs) and parent.
+	Control ControlConfig
+
+	// Listeners bound by the parent and passed to the forked child - not
+	// supported with `Programs` (an error).
+	Listeners []ListenerConfig
+
+	// Zero-downtime upgrade of the forked child on a signal.
+	Upgrade UpgradeConfig
+
+	// Rewriting table for the signals forwarded to the forked child (or
+	// the programs without a stop signal).
+	SignalRewrites SignalRewrites
+
+	// Scope the forwarded signals are delivered to - the child only (the
+	// default), its process group or its session.
+	ForwardScope SignalTarget
+
+	// Tear down the leftover descendants before the parent exits.
+	Teardown TeardownConfig
+
+	// Called (once) when all the children are done - aka there are no
+	// more children to reap (ECHILD) after at least one was reaped. With
+	// the child subreaper enabled, this is the whole descendant tree.
+	// See also `Reaper.Done`.
+	OnComplete func()
+
+	// Interval for a periodic safety reap sweep, disabled if zero. This
+	// guarantees progress even if a SIGCHLD is lost (coalesced or taken
+	// by another signal handler). See also `Reaper.ReapNow`.
+	SweepInterval time.Duration
+
+	// Pid 1 signal handlers (SIGTERM etc) for the in-process mode.
+	Pid1Signals Pid1SignalsConfig
+}
+
+// Handle to a running reaper.
+type Reaper struct {
+	config        Config
+	pid           int
+	notifications chan os.Signal
+	watchdog      *watchdog
+	pidBudget     *pidBudget
+	procConnector *procConnector
+
+	mutex    sync.Mutex
+	stats    Stats
+	signaled time.Time
+	owned    map[int]chan Status
+	jobs     chan Status
+
+	//  Pids supervised in forked mode - never killed by the watchdog.
+	supervised map[int]bool
+
+	done     chan struct{}
+	complete sync.Once
+	waiters  []*allWaiter
 }
 
 // Reaped child process status information.
@@ -34,6 +131,16 @@ type Status struct {
 	Pid        int
 	Err        error
 	WaitStatus syscall.WaitStatus
+
+	// Process lineage, only available with the proc connector enabled.
+	Lineage *Lineage
+
+	// Time the SIGCHLD was observed (zero if the child was reaped without
+	// one, for example by a sweep), the time the child was reaped and the
+	// child process start time (zero if not available).
+	Signaled time.Time
+	Reaped   time.Time
+	Started  time.Time
 }
 
 // Callback entry point [function] for WithReaper.
@@ -85,12 +192,12 @@ func callerCheck() error {
 } /*  End of function  callerCheck.  */
 
 // Send the child status on the status `ch` channel.
-func notify(ch chan Status, pid int, err error, ws syscall.WaitStatus) {
+func notify(ch chan Status, status Status) {
 	if ch == nil {
 		return
 	}
 
-	status := Status{Pid: pid, Err: err, WaitStatus: ws}
+	pid := status.Pid
 
 	// The only case for recovery would be if the caller closes the
 	// `StatusChannel`. That is not really something recommended or
@@ -117,15 +224,24 @@ func notify(ch chan Status, pid int, err error, ws syscall.WaitStatus) {
 } /*  End of function  notify.  */
 
 // Handle death of child messages (SIGCHLD). Pushes the signal onto the
-// notifications channel if there is a waiter.
-func sigChildHandler(notifications chan os.Signal) {
+// notifications channel if there is a waiter and relays it to the SIGCHLD
+// subscribers (`NotifySIGCHLD`).
+func (r *Reaper) sigChildHandler() {
 	var sigs = make(chan os.Signal, 3)
 	signal.Notify(sigs, syscall.SIGCHLD)
 
 	for {
 		var sig = <-sigs
+
+		//  Remember when the (first pending) SIGCHLD was observed.
+		r.mutex.Lock()
+		if r.signaled.IsZero() {
+			r.signaled = time.Now()
+		}
+		r.mutex.Unlock()
+
 		select {
-		case notifications <- sig: /*  published it.  */
+		case r.notifications <- sig: /*  published it.  */
 		default:
 			/*
 			 *  Notifications channel full - drop it to the
@@ -134,53 +250,272 @@ func sigChildHandler(notifications chan os.Signal) {
 			 *  process (pid=-1), so we ain't loosing it!! ;^)
 			 */
 		}
+
+		dispatchSIGCHLD(sig)
+	}
+
+} /*  End of method  Reaper.sigChildHandler.  */
+
+// Reap a single child process, waiting for one to exit unless WNOHANG is
+// set in the options. Returns a nil status if there is nothing to reap.
+func (r *Reaper) reapOne(pid, opts int) (*Status, error) {
+	peekable := opts&(syscall.WUNTRACED|syscall.WCONTINUED) == 0
+
+	for {
+		var wstatus syscall.WaitStatus
+		var started time.Time
+
+		/*
+		 *  Peek at the exited child without reaping it, so that we can
+		 *  get its start time from /proc before it is gone.
+		 */
+		wpid := pid
+		if peekable {
+			peeked, err := peekChild(pid, opts)
+			for syscall.EINTR == err {
+				peeked, err = peekChild(pid, opts)
+			}
+
+			switch {
+			case err == syscall.ECHILD:
+				return nil, err
+
+			case err == nil && peeked == 0:
+				return nil, nil /*  WNOHANG and nothing exited.  */
+
+			case err == nil:
+				wpid = peeked
+				if info, err := readProcess(peeked); err == nil {
+					started = info.StartTime
+				}
+			}
+		}
+
+		/*
+		 *  Reap 'em, so that zombies don't accumulate.
+		 *  Plants vs. Zombies!!
+		 */
+		rpid, err := syscall.Wait4(wpid, &wstatus, opts, nil)
+		for syscall.EINTR == err {
+			rpid, err = syscall.Wait4(wpid, &wstatus, opts, nil)
+		}
+
+		if syscall.ECHILD == err && wpid != pid {
+			/*  Peeked child got reaped by someone else.  */
+			continue
+		}
+
+		if syscall.ECHILD == err {
+			return nil, err
+		}
+
+		if err == nil && rpid == 0 {
+			return nil, nil /*  WNOHANG and nothing exited.  */
+		}
+
+		status := &Status{
+			Pid:        rpid,
+			Err:        err,
+			WaitStatus: wstatus,
+			Reaped:     time.Now(),
+			Started:    started,
+		}
+
+		return status, nil
 	}
 
-} /*  End of function  sigChildHandler.  */
+} /*  End of method  Reaper.reapOne.  */
 
 // Be a good parent - clean up behind the children.
-func reapChildren(config Config) {
-	var notifications = make(chan os.Signal, 1)
+func (r *Reaper) reapChildren() {
+	config := r.config
 
-	go sigChildHandler(notifications)
+	go r.sigChildHandler()
 
 	pid := config.Pid
 	opts := config.Options
-	informer := config.StatusChannel
 
 	for {
-		var sig = <-notifications
+		var sig = <-r.notifications
 		if config.Debug {
 			fmt.Printf(" - Received signal %+v\n", sig)
 		}
 		for {
-			var wstatus syscall.WaitStatus
-
 			/*
-			 *  Reap 'em, so that zombies don't accumulate.
-			 *  Plants vs. Zombies!!
+			 *  Reap the exited children first, so that we know
+			 *  when we have caught up with the pending SIGCHLDs.
 			 */
-			pid, err := syscall.Wait4(pid, &wstatus, opts, nil)
-			for syscall.EINTR == err {
-				pid, err = syscall.Wait4(pid, &wstatus, opts, nil)
+			before := time.Now()
+			status, err := r.reapOne(pid, opts|syscall.WNOHANG)
+			if status == nil && err == nil {
+				r.caughtUp(before)
+				if opts&syscall.WNOHANG == 0 {
+					status, err = r.reapOne(pid, opts)
+				}
 			}
 
 			if syscall.ECHILD == err {
+				r.caughtUp(before)
+				r.drained()
+				r.completed()
 				break
 			}
 
-			if config.Debug {
-				fmt.Printf(" - Grim reaper cleanup: pid=%d, wstatus=%+v\n",
-					pid, wstatus)
+			if status == nil {
+				break
 			}
 
-			if informer != nil {
-				go notify(informer, pid, err, wstatus)
-			}
+			r.reaped(status)
 		}
 	}
 
-} /*   End of function  reapChildren.  */
+} /*   End of method  Reaper.reapChildren.  */
+
+// All the children that exited before a sweep (pass) are reaped - forget
+// the SIGCHLD, unless it was observed after the pass started.
+func (r *Reaper) caughtUp(before time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.signaled.After(before) {
+		r.signaled = time.Time{}
+	}
+
+} /*  End of method  Reaper.caughtUp.  */
+
+// Handle a reaped (or stopped/continued) child status.
+func (r *Reaper) reaped(status *Status) {
+	if r.config.Debug {
+		fmt.Printf(" - Grim reaper cleanup: pid=%d, wstatus=%+v\n",
+			status.Pid, status.WaitStatus)
+	}
+
+	ws := status.WaitStatus
+	if status.Err == nil && (ws.Stopped() || ws.Continued()) {
+		/*  Job control (WUNTRACED), not gone yet.  */
+		r.jobControlled(*status)
+	} else if status.Err == nil {
+		//  The (first) pending SIGCHLD, kept until the sweep is done.
+		r.mutex.Lock()
+		status.Signaled = r.signaled
+		r.stats.observe(status)
+		r.mutex.Unlock()
+
+		if r.procConnector != nil {
+			status.Lineage = r.procConnector.claim(status.Pid)
+		}
+
+		if ch := r.disown(status.Pid); ch != nil {
+			ch <- *status
+		}
+
+		r.collect(*status)
+	}
+
+	if informer := r.config.StatusChannel; informer != nil {
+		go notify(informer, *status)
+	}
+
+} /*  End of method  Reaper.reaped.  */
+
+// No children left to reap - signal completion if we reaped any.
+func (r *Reaper) completed() {
+	r.mutex.Lock()
+	reaped := r.stats.Reaped > 0
+	r.mutex.Unlock()
+
+	if !reaped {
+		return
+	}
+
+	r.complete.Do(func() {
+		if r.config.Debug {
+			fmt.Println(" - Reaper done, no children left")
+		}
+
+		close(r.done)
+		if r.config.OnComplete != nil {
+			go r.config.OnComplete()
+		}
+	})
+
+} /*  End of method  Reaper.completed.  */
+
+// Trigger a reap sweep.
+func (r *Reaper) sweep() {
+	select {
+	case r.notifications <- syscall.SIGCHLD: /*  queued a sweep.  */
+	default: /*  sweep already pending.  */
+	}
+
+} /*  End of method  Reaper.sweep.  */
+
+// Periodically trigger a (safety) reap sweep.
+func (r *Reaper) sweeper() {
+	ticker := time.NewTicker(r.config.SweepInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		r.sweep()
+	}
+
+} /*  End of method  Reaper.sweeper.  */
+
+// Fork and exec a child process "owned" by the caller. The reaper still
+// reaps the child but also delivers its status on the returned channel.
+func (r *Reaper) forkExec(path string, args []string,
+	attrs *syscall.ProcAttr) (int, chan Status, error) {
+	//  Hold the lock across the fork, so that the reaper can't reap the
+	//  child before it is registered as owned.
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	pid, err := syscall.ForkExec(path, args, attrs)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	ch := make(chan Status, 1)
+	r.owned[pid] = ch
+
+	return pid, ch, nil
+
+} /*  End of method  Reaper.forkExec.  */
+
+// Deliver a stopped/continued status of an owned child process on the
+// job control channel (if any).
+func (r *Reaper) jobControlled(status Status) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, owned := r.owned[status.Pid]; owned && r.jobs != nil {
+		go func(ch chan Status) { ch <- status }(r.jobs)
+	}
+
+} /*  End of method  Reaper.jobControlled.  */
+
+// Set the job control channel for the owned child processes.
+func (r *Reaper) jobControl(ch chan Status) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.jobs = ch
+
+} /*  End of method  Reaper.jobControl.  */
+
+// Return [and forget] the status channel for an owned child process.
+func (r *Reaper) disown(pid int) chan Status {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ch, ok := r.owned[pid]
+	if ok {
+		delete(r.owned, pid)
+	}
+
+	return ch
+
+} /*  End of method  Reaper.disown.  */
 
 /*
  *  ======================================================================
@@ -198,6 +533,8 @@ func MakeConfig() Config {
 		DisableCallerCheck:   false,
 		CloneEnvIndicator:    DEFAULT_ENV_INDICATOR,
 
+		Child: ChildConfig{ParentDeathSignal: syscall.SIGKILL},
+
 		Debug: true,
 	}
 
@@ -218,7 +555,8 @@ func Reap() {
 // Entry point for invoking the reaper code with a specific configuration.
 // The config allows you to bypass the pid 1 checks, so handle with care.
 // The child processes are reaped in the background inside a goroutine.
-func Start(config Config) {
+// Returns a handle to the running reaper or nil if the reaper is disabled.
+func Start(config Config) *Reaper {
 	/*
 	 *  Start the Reaper with configuration options. This allows you to
 	 *  reap processes even if the current pid isn't running as pid 1.
@@ -245,32 +583,123 @@ func Start(config Config) {
 		mypid := os.Getpid()
 		if 1 != mypid {
 			fmt.Println(" - Grim reaper disabled, pid not 1")
-			return
+			return nil
 		}
 	}
 
+	r := &Reaper{
+		config:        config,
+		pid:           os.Getpid(),
+		notifications: make(chan os.Signal, 1),
+		stats:         makeStats(),
+		owned:         make(map[int]chan Status),
+		supervised:    make(map[int]bool),
+		done:          make(chan struct{}),
+	}
+
 	/*
 	 *  Ok, so either pid 1 checks are disabled or we are the grandma
 	 *  of 'em all, either way we get to play the grim reaper.
 	 *  You will be missed, Terry Pratchett!! RIP
 	 */
-	go reapChildren(config)
+	go r.reapChildren()
+
+	if config.SweepInterval > 0 {
+		go r.sweeper()
+	}
+
+	if config.Pid1Signals.Enable {
+		r.handlePid1Signals()
+	}
+
+	if config.ProcConnector.Enable {
+		r.procConnector = newProcConnector(r)
+		go r.procConnector.run()
+	}
+
+	if config.Watchdog.Interval > 0 {
+		r.watchdog = newWatchdog(r)
+		go r.watchdog.run()
+	}
+
+	if config.PidBudget.Interval > 0 {
+		r.pidBudget = newPidBudget(r)
+		go r.pidBudget.run()
+	}
+
+	return r
 
 } /*  End of [exported] function  Start.  */
 
+// Return a channel that is closed once there are no children left to reap
+// (ECHILD) after at least one child was reaped. With the child subreaper
+// enabled, that means the whole descendant tree has finished. The reaper
+// keeps running, so any children started later are still reaped. For a
+// disabled reaper (a nil handle), nothing is reaped and the channel is nil
+// (never closed) - check the handle.
+func (r *Reaper) Done() <-chan struct{} {
+	if r == nil {
+		return nil
+	}
+
+	return r.done
+
+} /*  End of [exported] method  Reaper.Done.  */
+
+// Reap any exited children right away (a `WNOHANG` sweep) and return their
+// statuses - for use when a SIGCHLD may have been lost. The statuses are
+// also delivered as usual (status channel, stats etc). Returns an error
+// for a disabled reaper (a nil handle).
+func (r *Reaper) ReapNow() ([]Status, error) {
+	if r == nil {
+		return []Status{}, fmt.Errorf("reaper is disabled")
+	}
+
+	statuses := make([]Status, 0)
+	for {
+		status, err := r.reapOne(r.config.Pid,
+			r.config.Options|syscall.WNOHANG)
+		if syscall.ECHILD == err {
+			r.drained()
+			r.completed()
+			return statuses, nil
+		}
+
+		if status == nil {
+			return statuses, nil
+		}
+
+		r.reaped(status)
+		if status.Err != nil {
+			return statuses, status.Err
+		}
+
+		statuses = append(statuses, *status)
+	}
+
+} /*  End of [exported] method  Reaper.ReapNow.  */
+
 // Run processes in forked mode patterned on "into the woods".
 // The parent process starts up the reaper and a new child process and
-// waits on the child process to terminate and exits.
+// waits on the child process to terminate and exits. Signals sent to the
+// parent are forwarded to the child and depending on the restart policy,
+// the parent restarts the child when it exits.
+// If `Programs` are configured, the parent runs and supervises those
+// instead of a child process and this call never returns.
 // This call will return back only in the forked child process.
 func RunForked(config Config) {
 	// Use an environment variable to indicate whether or not
 	// we are the child/parent.
 	indicator := envIndicator(config)
 
-	if _, hasReaper := os.LookupEnv(indicator); hasReaper {
+	if value, hasReaper := os.LookupEnv(indicator); hasReaper {
 		if config.Debug {
 			fmt.Printf(" - forked [reaper] child, pid = %d\n", os.Getpid())
 		}
+
+		config.Child.apply()
+		config.Child.watchParent(value)
+		activateListeners()
 		return
 	}
 
@@ -279,45 +708,24 @@ func RunForked(config Config) {
 		fmt.Println(" - Starting reaper ...")
 	}
 
-	go Start(config)
-
-	// Note: Optionally add an argument to the end to more easily
-	//       distinguish the parent and child in something like `ps` etc.
-	// args := append(os.Args, "#kiddo")
-	args := os.Args
-
-	pwd, err := os.Getwd()
-	if err != nil {
-		fmt.Printf(" - Reaper error getting cwd = %v, using /tmp\n", err)
-		pwd = "/tmp"
-	}
-
-	kidEnv := []string{fmt.Sprintf("%v=%d", indicator, os.Getpid())}
-
-	var wstatus syscall.WaitStatus
-	pattrs := &syscall.ProcAttr{
-		Dir: pwd,
-		Env: append(os.Environ(), kidEnv...),
-		Sys: &syscall.SysProcAttr{Setsid: true},
-		Files: []uintptr{
-			uintptr(syscall.Stdin),
-			uintptr(syscall.Stdout),
-			uintptr(syscall.Stderr),
-		},
+	if config.Child.TTY && isTerminal(syscall.Stdin) {
+		/*  Get notified when the child is stopped (job control).  */
+		config.Options |= syscall.WUNTRACED
 	}
 
-	pid, _ := syscall.ForkExec(args[0], args, pattrs)
+	//  The parent forwards the signals to the child instead.
+	config.Pid1Signals.Enable = false
 
-	if config.Debug {
-		fmt.Printf(" - reaper forked child pid = %d\n", pid)
-	}
+	r := Start(config)
 
-	_, err = syscall.Wait4(pid, &wstatus, 0, nil)
-	for syscall.EINTR == err {
-		_, err = syscall.Wait4(pid, &wstatus, 0, nil)
+	s, err := newSupervisor(r, config)
+	if err != nil {
+		fmt.Printf(" - Error: reaper supervisor: %v\n", err)
+		os.Exit(exitCodeNotStarted)
 	}
 
-	os.Exit(0)
+	//  Supervise the forked child (or programs) and exit.
+	os.Exit(s.run())
 
 } /*  End of [exported] function  RunForked.  */
 
@@ -367,3 +775,23 @@ func WithReaper(config Config, ep EntryPoint) {
 	os.Exit(ep(nil))
 
 } /*  End of [exported] function  WithReaper.  */
+
+// Wrapper to run reaper in forked mode with a "child entry point" that
+// gets a control channel client to talk to the reaper parent.
+func WithReaperControl(config Config, ep ControlEntryPoint) {
+	if ep == nil {
+		err := fmt.Errorf("entry point parameter is required")
+		fmt.Printf(" - Error: %v\n", err)
+		panic(err)
+	}
+
+	config.Control.Enable = true
+	WithReaper(config, func(err error) int {
+		if err != nil {
+			return ep(nil, err)
+		}
+
+		return ep(NewControlClient())
+	})
+
+} /*  End of [exported] function  WithReaperControl.  */
@@ -0,0 +1,152 @@
+package reaper
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Done is closed once all the children are reaped, never for a disabled
+// reaper.
+func TestDone(t *testing.T) {
+	var disabled *Reaper
+	if done := disabled.Done(); done != nil {
+		t.Errorf("disabled reaper done = %v, expected nil", done)
+	}
+
+	completed := make(chan struct{})
+	r := &Reaper{done: make(chan struct{}), stats: makeStats()}
+	r.config.OnComplete = func() { close(completed) }
+
+	r.completed()
+	select {
+	case <-r.Done():
+		t.Errorf("reaper done without reaping a child")
+	default:
+	}
+
+	r.stats.Reaped = 1
+	r.completed()
+	r.completed()
+
+	select {
+	case <-r.Done():
+	default:
+		t.Errorf("reaper not done after reaping all the children")
+	}
+
+	select {
+	case <-completed:
+	case <-time.After(time.Second):
+		t.Errorf("OnComplete was not called")
+	}
+
+} /*  End of function  TestDone.  */
+
+// WaitAll returns the statuses reaped until all the children are done.
+func TestWaitAll(t *testing.T) {
+	var disabled *Reaper
+	if _, err := disabled.WaitAll(context.Background()); err == nil {
+		t.Errorf("disabled reaper WaitAll, expected an error")
+	}
+
+	r := &Reaper{notifications: make(chan os.Signal, 1)}
+
+	type result struct {
+		statuses []Status
+		err      error
+	}
+
+	results := make(chan result, 1)
+	go func() {
+		statuses, err := r.WaitAll(context.Background())
+		results <- result{statuses, err}
+	}()
+
+	for i := 0; i < 100; i++ {
+		r.mutex.Lock()
+		waiting := len(r.waiters) > 0
+		r.mutex.Unlock()
+
+		if waiting {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	r.collect(Status{Pid: 42})
+	r.drained()
+
+	select {
+	case res := <-results:
+		if res.err != nil || len(res.statuses) != 1 ||
+			res.statuses[0].Pid != 42 {
+			t.Errorf("WaitAll = %+v, %v", res.statuses, res.err)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatalf("WaitAll did not return")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		50*time.Millisecond)
+	defer cancel()
+
+	_, err := r.WaitAll(ctx)
+	if err != context.DeadlineExceeded || len(r.waiters) > 0 {
+		t.Errorf("WaitAll = %v, %d waiters, expected a timeout", err,
+			len(r.waiters))
+	}
+
+} /*  End of function  TestWaitAll.  */
+
+// ReapNow reaps the exited children right away.
+func TestReapNow(t *testing.T) {
+	var disabled *Reaper
+	if _, err := disabled.ReapNow(); err == nil {
+		t.Errorf("disabled reaper ReapNow, expected an error")
+	}
+
+	attrs := &syscall.ProcAttr{Files: []uintptr{0, 1, 2}}
+	pid, err := syscall.ForkExec("/bin/sh", []string{"sh", "-c", "exit 3"},
+		attrs)
+	if err != nil {
+		t.Fatalf("start child: %v", err)
+	}
+
+	for i := 0; i < 250; i++ {
+		if info, err := readProcess(pid); err != nil || info.State == "Z" {
+			break
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	r := &Reaper{done: make(chan struct{}), stats: makeStats()}
+	r.config.Pid = pid
+
+	statuses, err := r.ReapNow()
+	if err != nil || len(statuses) != 1 || statuses[0].Pid != pid ||
+		statuses[0].WaitStatus.ExitStatus() != 3 {
+		t.Fatalf("ReapNow = %+v, %v", statuses, err)
+	}
+
+	if stats := r.Stats(); stats.Reaped != 1 {
+		t.Errorf("reaped = %d, expected 1", stats.Reaped)
+	}
+
+	select {
+	case <-r.Done():
+	default:
+		t.Errorf("reaper not done after reaping the child")
+	}
+
+	statuses, err = r.ReapNow()
+	if err != nil || len(statuses) != 0 {
+		t.Errorf("ReapNow = %+v, %v, expected nothing", statuses, err)
+	}
+
+} /*  End of function  TestReapNow.  */
@@ -0,0 +1,182 @@
+package reaper
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// Env variables for the systemd notify protocol (sd_notify).
+	notifySocketEnv = "NOTIFY_SOCKET"
+	watchdogPidEnv  = "WATCHDOG_PID"
+
+	// Max size of a notify message.
+	maxNotifyMessage = 4096
+)
+
+// Systemd notify (sd_notify) configuration - used when running under
+// systemd with `Type=notify`. The reaper parent sends `READY=1` (with its
+// pid as the `MAINPID`) once the forked child (or the main program) is
+// ready and `STOPPING=1` on a shutdown. The child gets its own notify
+// socket, the parent relays its `READY=1`, `STATUS=` and `WATCHDOG=1`
+// messages to systemd.
+type SystemdNotifyConfig struct {
+	// Enable the notify support, only used if NOTIFY_SOCKET is set.
+	Enable bool
+}
+
+// Systemd notifier and relay for the messages from the child.
+type sdNotifier struct {
+	conn  *net.UnixConn
+	relay *net.UnixConn
+	dir   string
+	path  string
+	once  sync.Once
+	debug bool
+}
+
+// Make a new systemd notifier - returns nil if NOTIFY_SOCKET is not set.
+// If the child runs as another user (the owner), the relay socket (and
+// its directory) are owned by that user, so that the child can send to it.
+func newSdNotifier(debug bool, owner *ChildUser) (*sdNotifier, error) {
+	socket := os.Getenv(notifySocketEnv)
+	if len(socket) == 0 {
+		return nil, nil
+	}
+
+	addr := &net.UnixAddr{Name: socket, Net: "unixgram"}
+	conn, err := net.DialUnix("unixgram", nil, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := ioutil.TempDir("", "reaper-notify")
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	path := filepath.Join(dir, "notify.sock")
+	relay, err := net.ListenUnixgram("unixgram",
+		&net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		conn.Close()
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	if owner != nil {
+		uid, gid := int(owner.Uid), int(owner.Gid)
+		err := os.Chown(dir, uid, gid)
+		if err == nil {
+			err = os.Chown(path, uid, gid)
+		}
+
+		if err != nil {
+			relay.Close()
+			conn.Close()
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("relay socket owner: %v", err)
+		}
+	}
+
+	n := &sdNotifier{
+		conn:  conn,
+		relay: relay,
+		dir:   dir,
+		path:  path,
+		debug: debug,
+	}
+
+	go n.run()
+	return n, nil
+
+} /*  End of function  newSdNotifier.  */
+
+// Return the environment for a child - only the child that reports its
+// readiness gets the relay socket.
+func (n *sdNotifier) env(env []string, notifies bool) []string {
+	kidEnv := make([]string, 0, len(env)+1)
+	for _, kv := range env {
+		switch envName(kv) {
+		case notifySocketEnv, watchdogPidEnv:
+			/*  Not the child's - it gets its own socket.  */
+		default:
+			kidEnv = append(kidEnv, kv)
+		}
+	}
+
+	if notifies {
+		kidEnv = append(kidEnv, fmt.Sprintf("%s=%s", notifySocketEnv, n.path))
+	}
+
+	return kidEnv
+
+} /*  End of method  sdNotifier.env.  */
+
+// Send a notify message (newline separated assignments) to systemd.
+func (n *sdNotifier) send(state ...string) {
+	msg := strings.Join(state, "\n")
+	if _, err := n.conn.Write([]byte(msg)); err != nil {
+		fmt.Printf(" - Error: sd_notify %q: %v\n", msg, err)
+	}
+
+	if n.debug {
+		fmt.Printf(" - sd_notify %q\n", msg)
+	}
+
+} /*  End of method  sdNotifier.send.  */
+
+// Notify systemd that we are ready (once).
+func (n *sdNotifier) ready() {
+	n.once.Do(func() {
+		n.send("READY=1", fmt.Sprintf("MAINPID=%d", os.Getpid()))
+	})
+
+} /*  End of method  sdNotifier.ready.  */
+
+// Notify systemd that we are stopping.
+func (n *sdNotifier) stopping() {
+	n.send("STOPPING=1")
+
+} /*  End of method  sdNotifier.stopping.  */
+
+// Relay the messages from the child.
+func (n *sdNotifier) run() {
+	buf := make([]byte, maxNotifyMessage)
+	for {
+		count, _, err := n.relay.ReadFromUnix(buf)
+		if err != nil {
+			return /*  Closed.  */
+		}
+
+		relayed := make([]string, 0)
+		for _, line := range strings.Split(string(buf[:count]), "\n") {
+			switch {
+			case line == "READY=1":
+				n.ready()
+
+			case strings.HasPrefix(line, "STATUS="), line == "WATCHDOG=1":
+				relayed = append(relayed, line)
+			}
+		}
+
+		if len(relayed) > 0 {
+			n.send(relayed...)
+		}
+	}
+
+} /*  End of method  sdNotifier.run.  */
+
+// Close the notifier and remove the relay socket.
+func (n *sdNotifier) close() {
+	n.relay.Close()
+	n.conn.Close()
+	os.RemoveAll(n.dir)
+
+} /*  End of method  sdNotifier.close.  */
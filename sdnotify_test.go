@@ -0,0 +1,146 @@
+package reaper
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// No notifier without a notify socket.
+func TestSdNotifierDisabled(t *testing.T) {
+	defer os.Setenv(notifySocketEnv, os.Getenv(notifySocketEnv))
+	os.Unsetenv(notifySocketEnv)
+
+	n, err := newSdNotifier(false, nil)
+	if n != nil || err != nil {
+		t.Errorf("notifier = %v, %v, expected nil", n, err)
+	}
+
+} /*  End of function  TestSdNotifierDisabled.  */
+
+// Child environment and the relayed messages.
+func TestSdNotifierRelay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reaper-systemd")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "systemd.sock")
+	systemd, err := net.ListenUnixgram("unixgram",
+		&net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("systemd socket: %v", err)
+	}
+
+	defer systemd.Close()
+
+	defer os.Setenv(notifySocketEnv, os.Getenv(notifySocketEnv))
+	os.Setenv(notifySocketEnv, path)
+
+	n, err := newSdNotifier(false, nil)
+	if err != nil || n == nil {
+		t.Fatalf("notifier = %v, %v", n, err)
+	}
+
+	defer n.close()
+
+	env := []string{"A=1", notifySocketEnv + "=" + path, watchdogPidEnv + "=1"}
+	if kidEnv := n.env(env, false); !reflect.DeepEqual(kidEnv, env[:1]) {
+		t.Errorf("env = %v, expected %v", kidEnv, env[:1])
+	}
+
+	expected := []string{"A=1", notifySocketEnv + "=" + n.path}
+	if kidEnv := n.env(env, true); !reflect.DeepEqual(kidEnv, expected) {
+		t.Errorf("env = %v, expected %v", kidEnv, expected)
+	}
+
+	child, err := net.Dial("unixgram", n.path)
+	if err != nil {
+		t.Fatalf("child dial: %v", err)
+	}
+
+	defer child.Close()
+
+	child.Write([]byte("READY=1\nSTATUS=up\nBOGUS=1\nWATCHDOG=1"))
+	child.Write([]byte("READY=1"))
+	child.Write([]byte("STATUS=still up"))
+
+	buf := make([]byte, maxNotifyMessage)
+	for _, msg := range []string{
+		fmt.Sprintf("READY=1\nMAINPID=%d", os.Getpid()),
+		"STATUS=up\nWATCHDOG=1",
+		"STATUS=still up",
+	} {
+		systemd.SetReadDeadline(time.Now().Add(5 * time.Second))
+		count, _, err := systemd.ReadFromUnix(buf)
+		if err != nil {
+			t.Fatalf("systemd read: %v", err)
+		}
+
+		if string(buf[:count]) != msg {
+			t.Errorf("message = %q, expected %q", buf[:count], msg)
+		}
+	}
+
+	n.stopping()
+	count, _, _ := systemd.ReadFromUnix(buf)
+	if string(buf[:count]) != "STOPPING=1" {
+		t.Errorf("message = %q, expected STOPPING=1", buf[:count])
+	}
+
+} /*  End of function  TestSdNotifierRelay.  */
+
+// The relay socket is owned by the user the child runs as.
+func TestSdNotifierOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing the owner needs root")
+	}
+
+	dir, err := ioutil.TempDir("", "reaper-systemd")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "systemd.sock")
+	systemd, err := net.ListenUnixgram("unixgram",
+		&net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatalf("systemd socket: %v", err)
+	}
+
+	defer systemd.Close()
+
+	defer os.Setenv(notifySocketEnv, os.Getenv(notifySocketEnv))
+	os.Setenv(notifySocketEnv, path)
+
+	owner := &ChildUser{Uid: 65534, Gid: 65534}
+	n, err := newSdNotifier(false, owner)
+	if err != nil || n == nil {
+		t.Fatalf("notifier = %v, %v", n, err)
+	}
+
+	defer n.close()
+
+	for _, name := range []string{n.dir, n.path} {
+		var stat syscall.Stat_t
+		if err := syscall.Stat(name, &stat); err != nil {
+			t.Fatalf("stat %v: %v", name, err)
+		}
+
+		if stat.Uid != owner.Uid || stat.Gid != owner.Gid {
+			t.Errorf("%v owner = %d:%d, expected %d:%d", name, stat.Uid,
+				stat.Gid, owner.Uid, owner.Gid)
+		}
+	}
+
+} /*  End of function  TestSdNotifierOwner.  */
@@ -0,0 +1,71 @@
+package reaper
+
+import (
+	"os"
+	"sync"
+)
+
+// SIGCHLD subscribers (the channels passed to NotifySIGCHLD).
+var sigChildSubscribers = struct {
+	sync.Mutex
+	channels []chan<- os.Signal
+}{}
+
+// Fan out a SIGCHLD to the subscribers - like `signal.Notify`, sends don't
+// block and the signal is dropped for a subscriber that isn't ready.
+func dispatchSIGCHLD(sig os.Signal) {
+	sigChildSubscribers.Lock()
+	defer sigChildSubscribers.Unlock()
+
+	for _, ch := range sigChildSubscribers.channels {
+		select {
+		case ch <- sig:
+		default:
+		}
+	}
+
+} /*  End of function  dispatchSIGCHLD.  */
+
+/*
+ *  ======================================================================
+ *  Section: Exported functions
+ *  ======================================================================
+ */
+
+// Relay the SIGCHLD signals received by the reaper to a channel - use this
+// instead of `signal.Notify` for SIGCHLD, so that the reaper and the other
+// SIGCHLD consumers are chained. The channel should be buffered.
+//
+// The subscribers are notified when the reaper is woken up for the
+// SIGCHLD, so the reaping is concurrent with (and with `Pid` -1 in the
+// config typically done before) their handling of the signal. A subscriber
+// should therefore not expect to `wait4` the child itself - it gets the
+// exit status on the `StatusChannel`, or the reaper `Pid` needs to exclude
+// the subscriber's children (for example a process group of the reaper's).
+func NotifySIGCHLD(ch chan<- os.Signal) {
+	if ch == nil {
+		panic("reaper: NotifySIGCHLD using nil channel")
+	}
+
+	sigChildSubscribers.Lock()
+	defer sigChildSubscribers.Unlock()
+
+	sigChildSubscribers.channels = append(sigChildSubscribers.channels, ch)
+
+} /*  End of [exported] function  NotifySIGCHLD.  */
+
+// Stop relaying the SIGCHLD signals to a channel.
+func StopSIGCHLD(ch chan<- os.Signal) {
+	sigChildSubscribers.Lock()
+	defer sigChildSubscribers.Unlock()
+
+	channels := sigChildSubscribers.channels
+	for idx, c := range channels {
+		if c == ch {
+			sigChildSubscribers.channels = append(channels[:idx],
+				channels[idx+1:]...)
+			return
+		}
+	}
+
+} /*  End of [exported] function  StopSIGCHLD.  */
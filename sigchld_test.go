@@ -0,0 +1,58 @@
+package reaper
+
+import (
+	"os"
+	"syscall"
+	"testing"
+)
+
+// SIGCHLD is relayed to the subscribers until they stop.
+func TestNotifySIGCHLD(t *testing.T) {
+	first := make(chan os.Signal, 1)
+	second := make(chan os.Signal, 1)
+
+	NotifySIGCHLD(first)
+	NotifySIGCHLD(second)
+	defer StopSIGCHLD(second)
+
+	dispatchSIGCHLD(syscall.SIGCHLD)
+	for _, ch := range []chan os.Signal{first, second} {
+		select {
+		case sig := <-ch:
+			if sig != syscall.SIGCHLD {
+				t.Errorf("relayed %v, expected SIGCHLD", sig)
+			}
+
+		default:
+			t.Errorf("SIGCHLD was not relayed")
+		}
+	}
+
+	//  A full subscriber doesn't block the others.
+	dispatchSIGCHLD(syscall.SIGCHLD)
+	dispatchSIGCHLD(syscall.SIGCHLD)
+	if len(first) != 1 || len(second) != 1 {
+		t.Errorf("relayed %d and %d, expected 1 each", len(first),
+			len(second))
+	}
+
+	<-first
+	<-second
+
+	StopSIGCHLD(first)
+	StopSIGCHLD(first)
+	dispatchSIGCHLD(syscall.SIGCHLD)
+	if len(first) != 0 || len(second) != 1 {
+		t.Errorf("relayed %d and %d after stop, expected 0 and 1",
+			len(first), len(second))
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("NotifySIGCHLD with a nil channel, expected a panic")
+		}
+	}()
+
+	NotifySIGCHLD(nil)
+
+} /*  End of function  TestNotifySIGCHLD.  */
@@ -0,0 +1,143 @@
+package reaper
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Target of a forwarded signal.
+type SignalTarget string
+
+const (
+	// The child process only (the default).
+	SignalTargetChild SignalTarget = "child"
+
+	// The child's process group.
+	SignalTargetGroup SignalTarget = "group"
+
+	// The rest of the child's process group (without the child).
+	SignalTargetRest SignalTarget = "rest"
+
+	// Every process in the child's session - the child needs to be in its
+	// own session (the default), otherwise its process group is used.
+	SignalTargetSession SignalTarget = "session"
+)
+
+// Delivery of a (rewritten) signal, the target defaults to the forward
+// scope.
+type SignalDelivery struct {
+	Signal syscall.Signal
+	Target SignalTarget
+}
+
+// Signal rewriting table for the forwarded signals (ala dumb-init's
+// --rewrite) - a received signal is delivered as the listed signals (and
+// targets) instead. A signal with no deliveries is dropped (a dropped
+// shutdown signal doesn't initiate a shutdown either) and a signal not in
+// the table is forwarded as is. For example:
+//
+//	reaper.SignalRewrites{
+//		syscall.SIGTERM: {
+//			{Signal: syscall.SIGQUIT},
+//			{Signal: syscall.SIGTERM, Target: reaper.SignalTargetRest},
+//		},
+//		syscall.SIGHUP: nil,
+//	}
+type SignalRewrites map[syscall.Signal][]SignalDelivery
+
+// Return the deliveries for a received signal.
+func (rw SignalRewrites) deliveries(sig syscall.Signal) []SignalDelivery {
+	if deliveries, ok := rw[sig]; ok {
+		return deliveries
+	}
+
+	return []SignalDelivery{{Signal: sig}}
+
+} /*  End of method  SignalRewrites.deliveries.  */
+
+// Check if a received signal is dropped.
+func (rw SignalRewrites) dropped(sig syscall.Signal) bool {
+	deliveries, ok := rw[sig]
+	return ok && len(deliveries) == 0
+
+} /*  End of method  SignalRewrites.dropped.  */
+
+// Return the processes (other than us and the excluded pid) that match.
+func processesOf(match func(p ProcessInfo) bool, exclude int) []int {
+	procs, err := listProcesses()
+	if err != nil {
+		return nil
+	}
+
+	self := os.Getpid()
+	pids := make([]int, 0)
+	for _, p := range procs {
+		if match(p) && p.Pid != exclude && p.Pid != self {
+			pids = append(pids, p.Pid)
+		}
+	}
+
+	return pids
+
+} /*  End of function  processesOf.  */
+
+// Send a signal to a target relative to a child process.
+func sendSignal(pid int, sig syscall.Signal, target SignalTarget) error {
+	switch target {
+	case "", SignalTargetChild:
+		return syscall.Kill(pid, sig)
+
+	case SignalTargetSession:
+		sid, err := unix.Getsid(pid)
+		if err != nil {
+			return err
+		}
+
+		if self, _ := unix.Getsid(0); sid == self {
+			/*  Not our session to signal.  */
+			return sendSignal(pid, sig, SignalTargetGroup)
+		}
+
+		match := func(p ProcessInfo) bool { return p.Sid == sid }
+		members := processesOf(match, 0)
+		if len(members) == 0 {
+			/*  No /proc, just the child then.  */
+			return syscall.Kill(pid, sig)
+		}
+
+		for _, member := range members {
+			syscall.Kill(member, sig)
+		}
+
+		return nil
+
+	case SignalTargetGroup, SignalTargetRest:
+		pgid, err := syscall.Getpgid(pid)
+		if err != nil {
+			return err
+		}
+
+		if target == SignalTargetGroup && pgid != syscall.Getpgrp() {
+			return syscall.Kill(-pgid, sig)
+		}
+
+		//  The rest of the group or the child shares our process group.
+		exclude := 0
+		if target == SignalTargetRest {
+			exclude = pid
+		}
+
+		match := func(p ProcessInfo) bool { return p.Pgid == pgid }
+		for _, member := range processesOf(match, exclude) {
+			syscall.Kill(member, sig)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unknown signal target %q", target)
+
+} /*  End of function  sendSignal.  */
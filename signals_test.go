@@ -0,0 +1,169 @@
+package reaper
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Rewritten, dropped and as is deliveries.
+func TestSignalRewrites(t *testing.T) {
+	rewrites := SignalRewrites{
+		syscall.SIGTERM: {
+			{Signal: syscall.SIGQUIT},
+			{Signal: syscall.SIGTERM, Target: SignalTargetRest},
+		},
+		syscall.SIGHUP:  nil,
+		syscall.SIGUSR1: {},
+	}
+
+	tests := []struct {
+		sig        syscall.Signal
+		deliveries []SignalDelivery
+		dropped    bool
+	}{
+		{syscall.SIGTERM, rewrites[syscall.SIGTERM], false},
+		{syscall.SIGHUP, nil, true},
+		{syscall.SIGUSR1, []SignalDelivery{}, true},
+		{syscall.SIGINT, []SignalDelivery{{Signal: syscall.SIGINT}}, false},
+	}
+
+	for _, tt := range tests {
+		deliveries := rewrites.deliveries(tt.sig)
+		if !reflect.DeepEqual(deliveries, tt.deliveries) {
+			t.Errorf("%v: deliveries = %+v, expected %+v", tt.sig,
+				deliveries, tt.deliveries)
+		}
+
+		if dropped := rewrites.dropped(tt.sig); dropped != tt.dropped {
+			t.Errorf("%v: dropped = %v, expected %v", tt.sig, dropped,
+				tt.dropped)
+		}
+	}
+
+	var none SignalRewrites
+	deliveries := none.deliveries(syscall.SIGTERM)
+	if len(deliveries) != 1 || deliveries[0].Signal != syscall.SIGTERM ||
+		none.dropped(syscall.SIGTERM) {
+		t.Errorf("no rewrites: deliveries = %+v", deliveries)
+	}
+
+} /*  End of function  TestSignalRewrites.  */
+
+// A dropped shutdown signal doesn't initiate a shutdown.
+func TestDroppedShutdownSignal(t *testing.T) {
+	s := &supervisor{}
+	s.config.SignalRewrites = SignalRewrites{syscall.SIGTERM: nil}
+
+	s.received(syscall.SIGTERM)
+	if s.shutdown {
+		t.Errorf("dropped SIGTERM initiated a shutdown")
+	}
+
+	s.received(syscall.SIGINT)
+	if !s.shutdown || s.stopSig != syscall.SIGINT {
+		t.Errorf("SIGINT did not initiate a shutdown")
+	}
+
+} /*  End of function  TestDroppedShutdownSignal.  */
+
+// Start a process group (or session) leader with a member - the leader
+// is a sleep, with a background sleep in its process group.
+func startGroup(t *testing.T, setsid bool) (*exec.Cmd, int) {
+	cmd := exec.Command("sh", "-c", "sleep 30 & echo $!; exec sleep 30")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: !setsid, Setsid: setsid}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("stdout: %v", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	line, _ := bufio.NewReader(stdout).ReadString('\n')
+	member, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil {
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		cmd.Wait()
+		t.Fatalf("member pid %q: %v", line, err)
+	}
+
+	//  Wait for the exec, so the leader gets the signals as a sleep.
+	for i := 0; i < 250; i++ {
+		if info, _ := readProcess(cmd.Process.Pid); info.Comm == "sleep" {
+			break
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	return cmd, member
+
+} /*  End of function  startGroup.  */
+
+// Check if a process is alive (and not a zombie).
+func isAlive(pid int) bool {
+	info, err := readProcess(pid)
+	return err == nil && info.State != "Z"
+
+} /*  End of function  isAlive.  */
+
+// Signals sent to the child, its process group, the rest of it or its
+// session.
+func TestSendSignal(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("no /proc")
+	}
+
+	tests := []struct {
+		target SignalTarget
+		setsid bool
+		leader bool
+		member bool
+	}{
+		{SignalTargetChild, false, true, false},
+		{"", false, true, false},
+		{SignalTargetGroup, false, true, true},
+		{SignalTargetRest, false, false, true},
+		{SignalTargetSession, true, true, true},
+	}
+
+	for _, tt := range tests {
+		cmd, member := startGroup(t, tt.setsid)
+		leader := cmd.Process.Pid
+
+		err := sendSignal(leader, syscall.SIGTERM, tt.target)
+		if err != nil {
+			t.Errorf("%q: send signal: %v", tt.target, err)
+		}
+
+		time.Sleep(200 * time.Millisecond)
+
+		if killed := !isAlive(leader); killed != tt.leader {
+			t.Errorf("%q: leader killed = %v, expected %v", tt.target,
+				killed, tt.leader)
+		}
+
+		if killed := !isAlive(member); killed != tt.member {
+			t.Errorf("%q: member killed = %v, expected %v", tt.target,
+				killed, tt.member)
+		}
+
+		syscall.Kill(-leader, syscall.SIGKILL)
+		syscall.Kill(member, syscall.SIGKILL)
+		cmd.Wait()
+	}
+
+	if err := sendSignal(os.Getpid(), 0, "bogus"); err == nil {
+		t.Errorf("unknown signal target, expected an error")
+	}
+
+} /*  End of function  TestSendSignal.  */
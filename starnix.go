@@ -6,6 +6,9 @@ package reaper
 /*  Note:  This is a *nix only implementation.  */
 
 import (
+	"syscall"
+	"unsafe"
+
 	"golang.org/x/sys/unix"
 )
 
@@ -14,3 +17,53 @@ func EnableChildSubReaper() error {
 	return unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0)
 
 } /*  End of [exported] function  EnableChildSubReaper.  */
+
+// Peek at an exited child process without reaping it (WNOWAIT). Returns
+// the pid of the child or 0 if WNOHANG is set and no child has exited.
+func peekChild(pid int, options int) (int, error) {
+	idtype, id := unix.P_ALL, 0
+	switch {
+	case pid > 0:
+		idtype, id = unix.P_PID, pid
+	case pid == 0:
+		idtype = unix.P_PGID /*  our process group.  */
+	case pid < -1:
+		idtype, id = unix.P_PGID, -pid
+	}
+
+	var info unix.Siginfo
+	flags := unix.WEXITED | unix.WNOWAIT | (options & unix.WNOHANG)
+	if err := unix.Waitid(idtype, id, &info, flags, nil); err != nil {
+		return 0, err
+	}
+
+	//  si_pid follows si_signo, si_errno and si_code in the (pointer
+	//  aligned) union.
+	offset := unsafe.Sizeof(uintptr(0)) * 2
+	if offset < 12 {
+		offset = 12
+	}
+
+	return int(*(*int32)(unsafe.Pointer(uintptr(unsafe.Pointer(&info)) +
+		offset))), nil
+
+} /*  End of function  peekChild.  */
+
+// Set the signal the child gets when the parent (thread) dies.
+func setParentDeathSignal(sys *syscall.SysProcAttr, sig syscall.Signal) {
+	sys.Pdeathsig = sig
+
+} /*  End of function  setParentDeathSignal.  */
+
+// Check if a file descriptor is a terminal.
+func isTerminal(fd int) bool {
+	_, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	return err == nil
+
+} /*  End of function  isTerminal.  */
+
+// Make a process group the foreground process group of a terminal.
+func setForeground(fd, pgrp int) error {
+	return unix.IoctlSetPointerInt(fd, unix.TIOCSPGRP, pgrp)
+
+} /*  End of function  setForeground.  */
@@ -0,0 +1,131 @@
+package reaper
+
+import (
+	"time"
+)
+
+var (
+	// Reap latency (SIGCHLD observed to reaped) histogram buckets.
+	reapLatencyBounds = []time.Duration{
+		100 * time.Microsecond, time.Millisecond, 10 * time.Millisecond,
+		100 * time.Millisecond, time.Second, 10 * time.Second,
+	}
+
+	// Child lifetime (start to reaped) histogram buckets.
+	lifetimeBounds = []time.Duration{
+		10 * time.Millisecond, 100 * time.Millisecond, time.Second,
+		10 * time.Second, time.Minute, 10 * time.Minute, time.Hour,
+		24 * time.Hour,
+	}
+)
+
+// Duration histogram. Counts[i] is the number of observations less than
+// or equal to Bounds[i] (and greater than the previous bound), the last
+// count is for the observations above the largest bound.
+type Histogram struct {
+	Bounds []time.Duration `json:"bounds"`
+	Counts []uint64        `json:"counts"`
+	Count  uint64          `json:"count"`
+	Sum    time.Duration   `json:"sum"`
+	Min    time.Duration   `json:"min"`
+	Max    time.Duration   `json:"max"`
+}
+
+// Reaper statistics.
+type Stats struct {
+	Reaped      uint64    `json:"reaped"`
+	PidUsage    PidUsage  `json:"pidUsage"`
+	ReapLatency Histogram `json:"reapLatency"`
+	Lifetime    Histogram `json:"lifetime"`
+}
+
+// Make a histogram with the specified bucket bounds.
+func makeHistogram(bounds []time.Duration) Histogram {
+	return Histogram{
+		Bounds: bounds,
+		Counts: make([]uint64, len(bounds)+1),
+	}
+
+} /*  End of function  makeHistogram.  */
+
+// Make empty reaper statistics.
+func makeStats() Stats {
+	return Stats{
+		ReapLatency: makeHistogram(reapLatencyBounds),
+		Lifetime:    makeHistogram(lifetimeBounds),
+	}
+
+} /*  End of function  makeStats.  */
+
+// Record an observation.
+func (h *Histogram) observe(d time.Duration) {
+	idx := len(h.Bounds)
+	for i, bound := range h.Bounds {
+		if d <= bound {
+			idx = i
+			break
+		}
+	}
+
+	h.Counts[idx]++
+	if h.Count == 0 || d < h.Min {
+		h.Min = d
+	}
+
+	if d > h.Max {
+		h.Max = d
+	}
+
+	h.Count++
+	h.Sum += d
+
+} /*  End of method  Histogram.observe.  */
+
+// Return a deep copy of the histogram.
+func (h Histogram) clone() Histogram {
+	h.Counts = append([]uint64(nil), h.Counts...)
+	return h
+
+} /*  End of method  Histogram.clone.  */
+
+// Return the mean of the observations.
+func (h Histogram) Mean() time.Duration {
+	if h.Count == 0 {
+		return 0
+	}
+
+	return h.Sum / time.Duration(h.Count)
+
+} /*  End of [exported] method  Histogram.Mean.  */
+
+// Record the statistics for a reaped child.
+func (s *Stats) observe(status *Status) {
+	s.Reaped++
+
+	if !status.Signaled.IsZero() {
+		s.ReapLatency.observe(status.Reaped.Sub(status.Signaled))
+	}
+
+	if !status.Started.IsZero() {
+		s.Lifetime.observe(status.Reaped.Sub(status.Started))
+	}
+
+} /*  End of method  Stats.observe.  */
+
+// Return a snapshot of the reaper statistics (empty if the reaper is
+// disabled aka a nil handle).
+func (r *Reaper) Stats() Stats {
+	if r == nil {
+		return makeStats()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stats := r.stats
+	stats.ReapLatency = stats.ReapLatency.clone()
+	stats.Lifetime = stats.Lifetime.clone()
+
+	return stats
+
+} /*  End of [exported] method  Reaper.Stats.  */
@@ -0,0 +1,71 @@
+package reaper
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Bucket observations and track the min, max and mean.
+func TestHistogramObserve(t *testing.T) {
+	h := makeHistogram([]time.Duration{time.Millisecond, time.Second})
+	for _, d := range []time.Duration{time.Second, 0, time.Millisecond,
+		time.Minute, 2 * time.Millisecond} {
+		h.observe(d)
+	}
+
+	if expected := []uint64{2, 2, 1}; !reflect.DeepEqual(h.Counts, expected) {
+		t.Errorf("counts = %v, expected %v", h.Counts, expected)
+	}
+
+	if h.Count != 5 || h.Min != 0 || h.Max != time.Minute {
+		t.Errorf("count = %d, min = %v, max = %v", h.Count, h.Min, h.Max)
+	}
+
+	expected := (time.Minute + time.Second + 3*time.Millisecond) / 5
+	if h.Mean() != expected {
+		t.Errorf("mean = %v, expected %v", h.Mean(), expected)
+	}
+
+	clone := h.clone()
+	clone.observe(0)
+	if h.Counts[0] != 2 {
+		t.Errorf("clone shares the counts with the histogram")
+	}
+
+	if empty := makeHistogram(nil); empty.Mean() != 0 {
+		t.Errorf("empty histogram mean = %v", empty.Mean())
+	}
+
+} /*  End of function  TestHistogramObserve.  */
+
+// No reap latency is recorded for a child reaped without a SIGCHLD.
+func TestStatsObserve(t *testing.T) {
+	now := time.Now()
+	stats := makeStats()
+
+	stats.observe(&Status{Reaped: now})
+	stats.observe(&Status{Signaled: now.Add(-time.Millisecond),
+		Started: now.Add(-time.Second), Reaped: now})
+
+	if stats.Reaped != 2 {
+		t.Errorf("reaped = %d, expected 2", stats.Reaped)
+	}
+
+	latency := stats.ReapLatency
+	if latency.Count != 1 || latency.Min != time.Millisecond {
+		t.Errorf("reap latency count = %d, min = %v", latency.Count,
+			latency.Min)
+	}
+
+	if stats.Lifetime.Count != 1 || stats.Lifetime.Max != time.Second {
+		t.Errorf("lifetime count = %d, max = %v", stats.Lifetime.Count,
+			stats.Lifetime.Max)
+	}
+
+	var r *Reaper
+	if disabled := r.Stats(); disabled.Reaped != 0 {
+		t.Errorf("disabled reaper stats = %+v", disabled)
+	}
+
+} /*  End of function  TestStatsObserve.  */
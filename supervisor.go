@@ -0,0 +1,1346 @@
+package reaper
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Restart policy for a supervised child process.
+type RestartPolicy string
+
+const (
+	RestartNever     RestartPolicy = "never"
+	RestartOnFailure RestartPolicy = "on-failure"
+	RestartAlways    RestartPolicy = "always"
+
+	// Default restart backoff settings.
+	defaultBackoff    = time.Second
+	defaultMaxBackoff = time.Minute
+
+	// Exit code used when a child process could not be started.
+	exitCodeNotStarted = 127
+)
+
+// Signals the parent forwards to the supervised child processes - the
+// termination signals also initiate a shutdown (aka no more restarts).
+var forwardedSignals = []os.Signal{
+	syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM,
+	syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGWINCH,
+}
+
+// Job control signals the parent handles (drops) in TTY mode.
+var jobControlSignals = []os.Signal{
+	syscall.SIGTSTP, syscall.SIGTTIN, syscall.SIGTTOU,
+}
+
+// Restart configuration for a supervised child process.
+type RestartConfig struct {
+	// Restart policy, defaults to never restarting the child.
+	Policy RestartPolicy
+
+	// Initial restart delay, doubled on every consecutive failure up to
+	// the max backoff (defaults to 1s and 1m). The backoff is reset once
+	// the child stays up for at least the max backoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Random jitter added to the delay as a fraction of it (0.0 - 1.0).
+	Jitter float64
+
+	// Max restarts within the window before giving up as the child is
+	// crash looping (0 = no limit). A zero window counts all restarts.
+	MaxRestarts int
+	Window      time.Duration
+}
+
+// Program run and supervised by the reaper parent (ala a Procfile entry).
+type Program struct {
+	// Name of the program (used in the events and logs).
+	Name string
+
+	// Path of the executable, looked up in $PATH if it has no slashes.
+	Path string
+
+	// Arguments including argv[0], defaults to just the path.
+	Args []string
+
+	// Additional environment variables (KEY=value) for the program.
+	Env []string
+
+	// Working directory, defaults to the parent's working directory.
+	Dir string
+
+	// Restart policy for the program.
+	Restart RestartConfig
+
+	// Signal sent to stop the program on a shutdown - defaults to
+	// forwarding the signal the parent received (or SIGTERM).
+	StopSignal syscall.Signal
+
+	// Grace period after the stop signal before the program is killed
+	// (SIGKILL) on a shutdown - 0 waits for the program to exit.
+	StopGracePeriod time.Duration
+
+	// The container (parent) exits when the main program or any critical
+	// program exits and is not going to be restarted.
+	Main     bool
+	Critical bool
+
+	// Names of the programs that need to be ready before this program is
+	// started. On a shutdown, programs are stopped in the reverse order.
+	DependsOn []string
+
+	// Readiness check for the program.
+	Ready ReadinessCheck
+}
+
+// Event data for the restart and crash loop events.
+type RestartInfo struct {
+	Name     string        `json:"name"`
+	Pid      int           `json:"pid"`
+	ExitCode int           `json:"exitCode"`
+	Restarts int           `json:"restarts"`
+	Delay    time.Duration `json:"delay"`
+}
+
+// Supervised child process.
+type program struct {
+	name    string
+	path    string
+	args    []string
+	attrs   *syscall.ProcAttr
+	opened  []*os.File
+	restart RestartConfig
+	stopSig syscall.Signal
+	grace   time.Duration
+	nice    int
+	tty     bool
+	main    bool
+	vital   bool
+	ready   ReadinessCheck
+	depends []string
+	deps    []*program
+
+	pid      int
+	gen      int
+	started  time.Time
+	exitCode int
+	failures int
+	restarts []time.Time
+	count    int
+	quit     chan struct{}
+	waiting  bool
+	isReady  bool
+	pending  bool
+	stopping bool
+	done     bool
+	status   Status
+	control  net.Conn
+	respawn  bool
+	upgrade  *program
+	retired  bool
+}
+
+// Reference to a specific run (start) of a program.
+type programRun struct {
+	program *program
+	gen     int
+}
+
+// Exit of a supervised child process.
+type programExit struct {
+	program *program
+	status  Status
+}
+
+// Supervisor for the forked child processes.
+type supervisor struct {
+	reaper   *Reaper
+	config   Config
+	programs []*program
+	signals  chan os.Signal
+	exits    chan programExit
+	restarts chan *program
+	readies  chan readiness
+	kills    chan programRun
+	controls chan controlRequest
+	jobs     chan Status
+	stops    chan os.Signal
+	output   *outputMux
+	notifier *sdNotifier
+	shutdown bool
+	stopSig  syscall.Signal
+	exitCode int
+	override bool
+	last     *program
+	trigger  *program
+
+	upgrading *program
+	retired   []*program
+
+	//  Bound listeners, kept open until the supervisor is done.
+	listeners []*boundListener
+}
+
+// Return the exit code for a wait status - 128 + signal if the process
+// was killed by a signal.
+func exitCode(ws syscall.WaitStatus) int {
+	switch {
+	case ws.Exited():
+		return ws.ExitStatus()
+
+	case ws.Signaled():
+		return 128 + int(ws.Signal())
+	}
+
+	return 0
+
+} /*  End of function  exitCode.  */
+
+// Check if a signal initiates a shutdown.
+func isShutdownSignal(sig os.Signal) bool {
+	switch sig {
+	case syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM:
+		return true
+	}
+
+	return false
+
+} /*  End of function  isShutdownSignal.  */
+
+// Fork and exec a child process and return a channel on which its exit
+// status gets delivered. If the reaper is running and waiting for any
+// child, it owns the reaping otherwise we just wait for the child. If the
+// jobs channel is set, the child's stops are delivered on it.
+func spawn(r *Reaper, path string, args []string, attrs *syscall.ProcAttr,
+	jobs chan Status) (int, chan Status, error) {
+	if r != nil && r.config.Pid == -1 {
+		if jobs != nil {
+			r.jobControl(jobs)
+		}
+
+		return r.forkExec(path, args, attrs)
+	}
+
+	pid, err := syscall.ForkExec(path, args, attrs)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	opts := 0
+	if jobs != nil {
+		opts = syscall.WUNTRACED
+	}
+
+	ch := make(chan Status, 1)
+	go func() {
+		for {
+			var wstatus syscall.WaitStatus
+
+			_, err := syscall.Wait4(pid, &wstatus, opts, nil)
+			for syscall.EINTR == err {
+				_, err = syscall.Wait4(pid, &wstatus, opts, nil)
+			}
+
+			status := Status{Pid: pid, Err: err, WaitStatus: wstatus,
+				Reaped: time.Now()}
+
+			if err == nil && wstatus.Stopped() {
+				jobs <- status
+				continue
+			}
+
+			ch <- status
+			return
+		}
+	}()
+
+	return pid, ch, nil
+
+} /*  End of function  spawn.  */
+
+// Make the forked child program - a re-exec of ourselves.
+func forkedChild(config Config) (*program, error) {
+	// Note: Optionally add an argument to the end to more easily
+	//       distinguish the parent and child in something like `ps` etc.
+	// args := append(os.Args, "#kiddo")
+	args := os.Args
+	child := config.Child
+
+	pwd := child.Dir
+	if len(pwd) == 0 {
+		cwd, err := os.Getwd()
+		if err != nil {
+			fmt.Printf(" - Reaper error getting cwd = %v, using /tmp\n", err)
+			cwd = "/tmp"
+		}
+
+		pwd = cwd
+	}
+
+	sys, err := child.sysProcAttr()
+	if err != nil {
+		return nil, err
+	}
+
+	files, opened, err := child.files()
+	if err != nil {
+		return nil, err
+	}
+
+	indicator := envIndicator(config)
+	kidEnv := []string{fmt.Sprintf("%v=%d", indicator, os.Getpid())}
+
+	return &program{
+		name: filepath.Base(args[0]),
+		path: args[0],
+		args: args,
+		attrs: &syscall.ProcAttr{
+			Dir:   pwd,
+			Env:   append(child.Env.build(), kidEnv...),
+			Sys:   sys,
+			Files: files,
+		},
+		opened:  opened,
+		restart: config.Restart,
+		nice:    child.Nice,
+		tty:     sys.Foreground,
+		main:    true,
+	}, nil
+
+} /*  End of function  forkedChild.  */
+
+// Make a supervised program from its configuration.
+func makeProgram(config Config, prog Program) (*program, error) {
+	path := prog.Path
+	if !strings.Contains(path, "/") {
+		resolved, err := exec.LookPath(path)
+		if err != nil {
+			return nil, err
+		}
+
+		path = resolved
+	}
+
+	args := prog.Args
+	if len(args) == 0 {
+		args = []string{prog.Path}
+	}
+
+	name := prog.Name
+	if len(name) == 0 {
+		name = filepath.Base(path)
+	}
+
+	dir := prog.Dir
+	if len(dir) == 0 {
+		pwd, err := os.Getwd()
+		if err != nil {
+			pwd = "/tmp"
+		}
+
+		dir = pwd
+	}
+
+	//  Programs also get the env indicator, so that a program that is
+	//  ourselves knows that it is running under the reaper.
+	indicator := envIndicator(config)
+	env := append(os.Environ(), prog.Env...)
+	env = append(env, fmt.Sprintf("%v=%d", indicator, os.Getpid()))
+
+	sys := &syscall.SysProcAttr{Setsid: true}
+	if sig := config.Child.ParentDeathSignal; sig != 0 {
+		setParentDeathSignal(sys, sig)
+	}
+
+	return &program{
+		name: name,
+		path: path,
+		args: args,
+		attrs: &syscall.ProcAttr{
+			Dir: dir,
+			Env: env,
+			Sys: sys,
+			Files: []uintptr{
+				uintptr(syscall.Stdin),
+				uintptr(syscall.Stdout),
+				uintptr(syscall.Stderr),
+			},
+		},
+		restart: prog.Restart,
+		stopSig: prog.StopSignal,
+		grace:   prog.StopGracePeriod,
+		main:    prog.Main,
+		vital:   prog.Critical,
+		ready:   prog.Ready,
+		depends: prog.DependsOn,
+	}, nil
+
+} /*  End of function  makeProgram.  */
+
+// Check if the program should be restarted after it exited.
+func (p *program) shouldRestart(ws syscall.WaitStatus) bool {
+	switch p.restart.Policy {
+	case RestartAlways:
+		return true
+
+	case RestartOnFailure:
+		return !ws.Exited() || ws.ExitStatus() != 0
+	}
+
+	return false
+
+} /*  End of method  program.shouldRestart.  */
+
+// Return the delay before the next restart (exponential backoff with
+// jitter) or false if the program is crash looping.
+func (p *program) nextDelay(now time.Time) (time.Duration, bool) {
+	rc := p.restart
+
+	backoff := rc.Backoff
+	if backoff <= 0 {
+		backoff = defaultBackoff
+	}
+
+	maxBackoff := rc.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+
+	if now.Sub(p.started) >= maxBackoff {
+		/*  Stayed up long enough, start afresh.  */
+		p.failures = 0
+	}
+
+	if rc.MaxRestarts > 0 {
+		recent := make([]time.Time, 0, len(p.restarts))
+		for _, t := range p.restarts {
+			if rc.Window <= 0 || now.Sub(t) < rc.Window {
+				recent = append(recent, t)
+			}
+		}
+
+		p.restarts = recent
+		if len(recent) >= rc.MaxRestarts {
+			return 0, false
+		}
+
+		p.restarts = append(p.restarts, now)
+	}
+
+	delay := backoff
+	for i := 0; i < p.failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+
+	if rc.Jitter > 0 {
+		delay += time.Duration(rand.Float64() * rc.Jitter * float64(delay))
+	}
+
+	p.failures++
+	p.count++
+
+	return delay, true
+
+} /*  End of method  program.nextDelay.  */
+
+// Resolve the program dependencies and check for cycles.
+func resolveDependencies(programs []*program) error {
+	byName := make(map[string]*program, len(programs))
+	for _, p := range programs {
+		if _, dup := byName[p.name]; dup {
+			return fmt.Errorf("duplicate program name %q", p.name)
+		}
+
+		byName[p.name] = p
+	}
+
+	for _, p := range programs {
+		p.deps = nil
+		for _, name := range p.depends {
+			dep, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("program %q depends on unknown %q",
+					p.name, name)
+			}
+
+			p.deps = append(p.deps, dep)
+		}
+	}
+
+	//  Depth first search for cycles.
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make(map[*program]int, len(programs))
+
+	var visit func(p *program) error
+	visit = func(p *program) error {
+		switch state[p] {
+		case visiting:
+			return fmt.Errorf("dependency cycle via %q", p.name)
+		case visited:
+			return nil
+		}
+
+		state[p] = visiting
+		for _, dep := range p.deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+
+		state[p] = visited
+		return nil
+	}
+
+	for _, p := range programs {
+		if err := visit(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+} /*  End of function  resolveDependencies.  */
+
+// Make a new supervisor for the forked child or the configured programs.
+func newSupervisor(r *Reaper, config Config) (*supervisor, error) {
+	if len(config.Programs) > 0 && len(config.Listeners) > 0 {
+		return nil, fmt.Errorf("listeners are only passed to the forked " +
+			"child, not to programs")
+	}
+
+	programs := []*program{}
+	listeners := []*boundListener{}
+	if len(config.Programs) == 0 {
+		p, err := forkedChild(config)
+		if err != nil {
+			return nil, fmt.Errorf("forked child: %v", err)
+		}
+
+		//  Kept open (by us) across restarts of the child.
+		listeners, err = bindListeners(config.Listeners)
+		if err != nil {
+			return nil, err
+		}
+
+		passListeners(p.attrs, listeners)
+		programs = append(programs, p)
+	}
+
+	for _, prog := range config.Programs {
+		p, err := makeProgram(config, prog)
+		if err != nil {
+			return nil, fmt.Errorf("program %q: %v", prog.Name, err)
+		}
+
+		programs = append(programs, p)
+	}
+
+	if err := resolveDependencies(programs); err != nil {
+		return nil, err
+	}
+
+	for _, p := range programs {
+		p.waiting = true
+	}
+
+	var output *outputMux
+	tailSize := 0
+	if len(config.Termination.Path) > 0 {
+		tailSize = config.Termination.StderrLines
+	}
+
+	if config.Output.Capture || tailSize > 0 {
+		output = newOutputMux(config.Output, tailSize)
+	}
+
+	var notifier *sdNotifier
+	if config.SystemdNotify.Enable {
+		//  The forked child may run as another user.
+		var owner *ChildUser
+		if len(config.Programs) == 0 {
+			owner = config.Child.User
+		}
+
+		n, err := newSdNotifier(config.Debug, owner)
+		if err != nil {
+			fmt.Printf(" - Error: sd_notify: %v\n", err)
+		}
+
+		notifier = n
+	}
+
+	if notifier != nil {
+		for _, p := range programs {
+			p.attrs.Env = notifier.env(p.attrs.Env, p.main)
+		}
+	}
+
+	return &supervisor{
+		reaper:   r,
+		config:   config,
+		programs: programs,
+		signals:  make(chan os.Signal, 8),
+		exits:    make(chan programExit, len(programs)),
+		restarts: make(chan *program, len(programs)),
+		readies:  make(chan readiness, len(programs)),
+		kills:    make(chan programRun, len(programs)),
+		controls: make(chan controlRequest, len(programs)),
+		jobs:     make(chan Status, len(programs)),
+		stops:    make(chan os.Signal, 1),
+		output:   output,
+		notifier: notifier,
+		stopSig:  syscall.SIGTERM,
+
+		listeners: listeners,
+	}, nil
+
+} /*  End of function  newSupervisor.  */
+
+// Pass an (additional) file to a child process, its fd number is set in
+// the env variable.
+func passFile(attrs *syscall.ProcAttr, f *os.File, env string) {
+	files, vars := len(attrs.Files), len(attrs.Env)
+
+	attrs.Files = append(attrs.Files[:files:files], f.Fd())
+	attrs.Env = append(attrs.Env[:vars:vars], fmt.Sprintf("%s=%d", env, files))
+
+} /*  End of function  passFile.  */
+
+// Start a program - a failure to start is handled as if the program
+// exited with `exitCodeNotStarted`.
+func (s *supervisor) start(p *program) {
+	p.gen++
+	p.started = time.Now()
+	p.waiting = false
+	p.pending = false
+	p.stopping = false
+	p.isReady = false
+
+	attrs := *p.attrs
+
+	//  The child's ends of the pipes, closed once the child is started.
+	ends := make([]*os.File, 0)
+	if s.output != nil {
+		files, captured, err := s.output.capture(p.name, attrs.Files)
+		if err != nil {
+			fmt.Printf(" - Error: %v output capture: %v\n", p.name, err)
+		}
+
+		attrs.Files = files
+		ends = append(ends, captured...)
+	}
+
+	//  Readiness pipe - the child gets the write end.
+	var readyPipe *os.File
+	if p.ready.Pipe {
+		r, w, err := os.Pipe()
+		if err != nil {
+			fmt.Printf(" - Error: %v readiness pipe: %v\n", p.name, err)
+		} else {
+			passFile(&attrs, w, ReadyFdEnv)
+			readyPipe = r
+			ends = append(ends, w)
+		}
+	}
+
+	//  Control socket - the child gets the other end.
+	var control *os.File
+	if s.config.Control.Enable {
+		parentEnd, childEnd, err := controlSocketPair()
+		if err != nil {
+			fmt.Printf(" - Error: %v control socket: %v\n", p.name, err)
+		} else {
+			passFile(&attrs, childEnd, ControlFdEnv)
+			control = parentEnd
+			ends = append(ends, childEnd)
+		}
+	}
+
+	var jobs chan Status
+	if p.tty {
+		jobs = s.jobs
+	}
+
+	pid, ch, err := spawn(s.reaper, p.path, p.args, &attrs, jobs)
+	for _, f := range ends {
+		f.Close()
+	}
+
+	if err != nil {
+		fmt.Printf(" - Error: reaper failed to start %v: %v\n", p.name, err)
+		for _, f := range []*os.File{readyPipe, control} {
+			if f != nil {
+				f.Close()
+			}
+		}
+
+		ws := syscall.WaitStatus(exitCodeNotStarted << 8)
+		go func() {
+			s.exits <- programExit{program: p, status: Status{WaitStatus: ws}}
+		}()
+		return
+	}
+
+	p.pid = pid
+	p.quit = make(chan struct{})
+	s.reaper.supervise(pid, true)
+	if p.nice != 0 {
+		if err := setNice(pid, p.nice); err != nil {
+			fmt.Printf(" - Error: %v nice %d: %v\n", p.name, p.nice, err)
+		}
+	}
+	if s.config.Debug {
+		fmt.Printf(" - reaper forked child %v pid = %d\n", p.name, pid)
+	}
+
+	go func() {
+		status := <-ch
+		s.reaper.supervise(pid, false)
+		s.exits <- programExit{program: p, status: status}
+	}()
+
+	run := programRun{program: p, gen: p.gen}
+	if control != nil {
+		conn, err := controlConn(control)
+		if err != nil {
+			fmt.Printf(" - Error: %v control socket: %v\n", p.name, err)
+		} else {
+			p.control = conn
+			go readControl(conn, func(msg ControlMessage) {
+				s.controls <- controlRequest{programRun: run, message: msg}
+			})
+		}
+	}
+
+	if !p.ready.enabled() {
+		p.isReady = true
+		return
+	}
+
+	go func(quit chan struct{}) {
+		ok := waitReady(p.ready, readyPipe, quit)
+		s.readies <- readiness{program: run.program, gen: run.gen, ready: ok}
+	}(p.quit)
+
+} /*  End of method  supervisor.start.  */
+
+// Start the programs waiting on dependencies that are now ready.
+func (s *supervisor) schedule() {
+	for changed := true; changed && !s.shutdown; {
+		changed = false
+
+		for _, p := range s.programs {
+			if !p.waiting {
+				continue
+			}
+
+			ready, failed := true, false
+			for _, dep := range p.deps {
+				ready = ready && dep.pid > 0 && dep.isReady
+				failed = failed || dep.done
+			}
+
+			switch {
+			case failed:
+				fmt.Printf(" - Error: %v dependencies failed\n", p.name)
+				p.waiting = false
+				p.exitCode = exitCodeNotStarted
+				s.finish(p)
+				changed = true
+
+			case ready:
+				s.start(p)
+				changed = true
+			}
+
+			if s.shutdown {
+				return
+			}
+		}
+	}
+
+	s.notifyReady()
+
+} /*  End of method  supervisor.schedule.  */
+
+// Notify systemd once the main program (or all the programs if there is
+// no main program) is ready. A main program without readiness checks
+// reports its readiness itself (sd_notify READY=1).
+func (s *supervisor) notifyReady() {
+	if s.notifier == nil || s.shutdown {
+		return
+	}
+
+	hasMain := s.hasMain()
+	for _, p := range s.programs {
+		if p.main && !p.ready.enabled() {
+			return
+		}
+
+		if (p.main || !hasMain) && !(p.pid > 0 && p.isReady) {
+			return
+		}
+	}
+
+	s.notifier.ready()
+
+} /*  End of method  supervisor.notifyReady.  */
+
+// Send a signal to a running program.
+func (s *supervisor) signal(p *program, sig syscall.Signal) {
+	if p.pid <= 0 {
+		return
+	}
+
+	if err := syscall.Kill(p.pid, sig); err != nil && s.config.Debug {
+		fmt.Printf(" - Error sending %v to %v pid %d: %v\n", sig, p.name,
+			p.pid, err)
+	}
+
+} /*  End of method  supervisor.signal.  */
+
+// Deliver a received signal to a running program as per the signal
+// rewriting table.
+func (s *supervisor) deliver(p *program, sig syscall.Signal) {
+	if p.pid <= 0 {
+		return
+	}
+
+	for _, d := range s.config.SignalRewrites.deliveries(sig) {
+		target := d.Target
+		if len(target) == 0 {
+			target = s.config.ForwardScope
+		}
+
+		err := sendSignal(p.pid, d.Signal, target)
+		if err != nil && s.config.Debug {
+			fmt.Printf(" - Error sending %v (%v) to %v pid %d: %v\n",
+				d.Signal, target, p.name, p.pid, err)
+		}
+	}
+
+} /*  End of method  supervisor.deliver.  */
+
+// Forward a signal to the running programs - except for SIGWINCH to the
+// programs in the foreground of the terminal, the kernel sends it to them.
+func (s *supervisor) forward(sig os.Signal) {
+	for _, p := range s.programs {
+		if p.tty && sig == syscall.SIGWINCH {
+			continue
+		}
+
+		s.deliver(p, sig.(syscall.Signal))
+	}
+
+} /*  End of method  supervisor.forward.  */
+
+// Handle a signal received by the parent - upgrade, drop, shutdown or
+// forward it.
+func (s *supervisor) received(sig os.Signal) {
+	switch {
+	case sig == s.config.Upgrade.Signal:
+		s.upgrade()
+
+	case s.config.SignalRewrites.dropped(sig.(syscall.Signal)):
+		if s.config.Debug {
+			fmt.Printf(" - Reaper dropped signal %v\n", sig)
+		}
+
+	case isShutdownSignal(sig):
+		s.stop(sig.(syscall.Signal))
+
+	default:
+		s.forward(sig)
+	}
+
+} /*  End of method  supervisor.received.  */
+
+// Terminate a program with its stop signal (or deliver the specified
+// signal) and kill it if it doesn't exit within its grace period.
+func (s *supervisor) terminate(p *program, sig syscall.Signal) {
+	if p.pid <= 0 || p.stopping {
+		return
+	}
+
+	p.stopping = true
+	if p.stopSig != 0 {
+		s.signal(p, p.stopSig)
+	} else {
+		s.deliver(p, sig)
+	}
+
+	if p.grace > 0 {
+		run := programRun{program: p, gen: p.gen}
+		time.AfterFunc(p.grace, func() { s.kills <- run })
+	}
+
+} /*  End of method  supervisor.terminate.  */
+
+// Stop the running programs that no running program depends on - aka in
+// the reverse order of the dependencies.
+func (s *supervisor) stopRunning() {
+	for _, p := range s.programs {
+		if p.pid <= 0 || p.stopping {
+			continue
+		}
+
+		needed := false
+		for _, q := range s.programs {
+			for _, dep := range q.deps {
+				needed = needed || (dep == p && q.pid > 0)
+			}
+		}
+
+		if !needed {
+			s.terminate(p, s.stopSig)
+		}
+	}
+
+} /*  End of method  supervisor.stopRunning.  */
+
+// Tell a program (over its control channel) that it is being stopped.
+func (s *supervisor) notifyStopping(p *program) {
+	if p.control == nil || p.pid <= 0 {
+		return
+	}
+
+	msg := ControlMessage{Type: ControlStopping}
+	if p.grace > 0 {
+		deadline := time.Now().Add(p.grace)
+		msg.Deadline = &deadline
+	}
+
+	writeControl(p.control, msg)
+
+} /*  End of method  supervisor.notifyStopping.  */
+
+// Retire a program (replaced or rolled back upgrade) - it is not
+// supervised anymore, but waited for before exiting.
+func (s *supervisor) retire(p *program) {
+	p.retired = true
+	p.pending = false
+	s.retired = append(s.retired, p)
+
+} /*  End of method  supervisor.retire.  */
+
+// Shutdown - stop all the programs with their stop signal (or the signal
+// received by the parent) and don't restart them anymore.
+func (s *supervisor) stop(sig syscall.Signal) {
+	if s.shutdown {
+		return
+	}
+
+	if s.config.Debug {
+		fmt.Printf(" - Reaper shutting down (%v) ...\n", sig)
+	}
+
+	s.shutdown = true
+	s.stopSig = sig
+	if s.notifier != nil {
+		s.notifier.stopping()
+	}
+
+	for _, p := range s.programs {
+		s.notifyStopping(p)
+	}
+
+	if next := s.upgrading; next != nil {
+		s.upgrading = nil
+		s.retire(next)
+		s.terminate(next, sig)
+	}
+	for _, p := range s.programs {
+		if p.pending || p.waiting {
+			/*  Not running and won't be now.  */
+			p.pending = false
+			p.waiting = false
+			p.done = true
+		}
+	}
+
+	s.stopRunning()
+
+} /*  End of method  supervisor.stop.  */
+
+// Handle the exit of a program.
+func (s *supervisor) exited(exit programExit) {
+	p := exit.program
+	ws := exit.status.WaitStatus
+
+	if p.quit != nil {
+		close(p.quit)
+		p.quit = nil
+	}
+
+	if p.control != nil {
+		p.control.Close()
+		p.control = nil
+	}
+
+	if p.tty {
+		/*  Take back the terminal.  */
+		s.foreground(syscall.Getpgrp())
+	}
+
+	if p.retired || p.upgrade != nil {
+		/*  Old (upgraded) or new (upgrading) copy of the program.  */
+		s.upgradeExited(p, exit)
+		return
+	}
+
+	p.pid = 0
+	p.isReady = false
+	p.status = exit.status
+	p.exitCode = exitCode(ws)
+	if s.trigger == nil && (p.main || !s.hasMain()) {
+		s.last = p
+		if !s.override {
+			s.exitCode = p.exitCode
+		}
+	}
+
+	if s.config.Debug {
+		fmt.Printf(" - reaper child %v exited, code = %d\n", p.name,
+			p.exitCode)
+	}
+
+	if p.respawn && !s.shutdown {
+		/*  Restart requested by the program itself.  */
+		p.respawn = false
+		p.pending = true
+		go func() { s.restarts <- p }()
+		return
+	}
+
+	if s.shutdown || !p.shouldRestart(ws) {
+		s.finish(p)
+		return
+	}
+
+	delay, ok := p.nextDelay(time.Now())
+	info := RestartInfo{
+		Name:     p.name,
+		Pid:      exit.status.Pid,
+		ExitCode: p.exitCode,
+		Restarts: p.count,
+		Delay:    delay,
+	}
+
+	if !ok {
+		fmt.Printf(" - Error: %v is crash looping, giving up\n", p.name)
+		event := makeEvent(EventCrashLoop, info.Pid, info,
+			"%v crash looping after %d restarts", p.name, p.count)
+		publish(s.config.EventChannel, event)
+
+		s.finish(p)
+		return
+	}
+
+	if s.config.Debug {
+		fmt.Printf(" - Restarting %v in %v ...\n", p.name, delay)
+	}
+
+	event := makeEvent(EventRestart, info.Pid, info,
+		"restarting %v (exit code %d) in %v", p.name, p.exitCode, delay)
+	publish(s.config.EventChannel, event)
+
+	p.pending = true
+	time.AfterFunc(delay, func() { s.restarts <- p })
+
+} /*  End of method  supervisor.exited.  */
+
+// A program is done (won't be restarted) - if it is the main or a critical
+// program, then shutdown everything else.
+func (s *supervisor) finish(p *program) {
+	p.done = true
+
+	if s.shutdown {
+		/*  Next in line (reverse dependency order) to stop.  */
+		s.stopRunning()
+		return
+	}
+
+	if !(p.main || p.vital) {
+		s.schedule()
+		return
+	}
+
+	if s.config.Debug {
+		fmt.Printf(" - %v exited, stopping all programs\n", p.name)
+	}
+
+	s.trigger = p
+	s.last = p
+	if !s.override {
+		s.exitCode = p.exitCode
+	}
+	s.stop(syscall.SIGTERM)
+
+} /*  End of method  supervisor.finish.  */
+
+// Handle the readiness of a program.
+func (s *supervisor) readied(r readiness) {
+	p := r.program
+	if p.gen != r.gen || p.pid <= 0 || p.retired {
+		return /*  Stale - program exited, restarted or retired.  */
+	}
+
+	if !r.ready {
+		switch {
+		case p.isReady:
+			/*  Already ready (upgrade timeout).  */
+
+		case p.upgrade != nil:
+			s.rollback(p, "not ready")
+
+		default:
+			fmt.Printf(" - Error: %v not ready, stopping it\n", p.name)
+			s.terminate(p, syscall.SIGTERM)
+		}
+
+		return
+	}
+
+	if p.upgrade != nil {
+		p.isReady = true
+		s.promote(p)
+		return
+	}
+
+	if s.config.Debug {
+		fmt.Printf(" - %v is ready\n", p.name)
+	}
+
+	p.isReady = true
+	s.schedule()
+
+} /*  End of method  supervisor.readied.  */
+
+// Handle a control message from a program.
+func (s *supervisor) controlled(req controlRequest) {
+	p := req.program
+	if p.gen != req.gen || p.pid <= 0 || p.retired {
+		return /*  Stale - program exited, restarted or retired.  */
+	}
+
+	if s.config.Debug {
+		fmt.Printf(" - %v control %v\n", p.name, req.message.Type)
+	}
+
+	if p.upgrade != nil {
+		/*  Only readiness matters while upgrading.  */
+		if req.message.Type == ControlReady {
+			p.isReady = true
+			s.promote(p)
+		}
+
+		return
+	}
+
+	switch req.message.Type {
+	case ControlReady:
+		if p.main && s.notifier != nil {
+			s.notifier.ready()
+		}
+
+		if !p.isReady {
+			p.isReady = true
+			s.schedule()
+		}
+
+	case ControlRestart:
+		if !s.shutdown {
+			p.respawn = true
+			s.terminate(p, syscall.SIGTERM)
+		}
+
+	case ControlShutdown:
+		s.stop(syscall.SIGTERM)
+
+	case ControlExitCode:
+		s.override = true
+		s.exitCode = req.message.ExitCode
+	}
+
+} /*  End of method  supervisor.controlled.  */
+
+// Make a process group the foreground process group of the terminal. As
+// we are in the background, SIGTTOU needs to be ignored while doing this.
+func (s *supervisor) foreground(pgrp int) error {
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Notify(s.stops, jobControlSignals...)
+
+	return setForeground(syscall.Stdin, pgrp)
+
+} /*  End of method  supervisor.foreground.  */
+
+// Handle a (TTY mode) program that got stopped - there is no job control
+// shell to resume it, so give it the terminal and resume it.
+func (s *supervisor) jobControlled(status Status) {
+	ws := status.WaitStatus
+	if !ws.Stopped() {
+		return
+	}
+
+	for _, p := range s.programs {
+		if p.pid != status.Pid || !p.tty {
+			continue
+		}
+
+		if s.config.Debug {
+			fmt.Printf(" - %v stopped (%v), resuming it\n", p.name,
+				unix.SignalName(ws.StopSignal()))
+		}
+
+		if err := s.foreground(p.pid); err != nil {
+			fmt.Printf(" - Error: %v foreground: %v\n", p.name, err)
+		}
+
+		syscall.Kill(-p.pid, syscall.SIGCONT)
+	}
+
+} /*  End of method  supervisor.jobControlled.  */
+
+// Check if there is a main program.
+func (s *supervisor) hasMain() bool {
+	for _, p := range s.programs {
+		if p.main {
+			return true
+		}
+	}
+
+	return false
+
+} /*  End of method  supervisor.hasMain.  */
+
+// Check if all the programs are done (and the retired ones exited).
+func (s *supervisor) finished() bool {
+	for _, p := range s.programs {
+		if !p.done {
+			return false
+		}
+	}
+
+	return len(s.retired) == 0 && s.upgrading == nil
+
+} /*  End of method  supervisor.finished.  */
+
+// Write the termination report for the program that determined the exit
+// code.
+func (s *supervisor) terminated() {
+	config := s.config.Termination
+	if len(config.Path) == 0 || s.last == nil {
+		return
+	}
+
+	stderr := []string{}
+	if s.output != nil {
+		stderr = s.output.tail(s.last.name)
+	}
+
+	t := makeTermination(s.last, stderr)
+	if err := writeTermination(config, t); err != nil {
+		fmt.Printf(" - Error: writing termination to %v: %v\n",
+			config.Path, err)
+	}
+
+} /*  End of method  supervisor.terminated.  */
+
+// Run the supervisor and return the parent's exit code - the exit code of
+// the main program (or of the program that caused the shutdown) if it is
+// propagated or set over the control channel, 0 otherwise.
+func (s *supervisor) run() int {
+	//  The parent death signal is sent when the thread that forked the
+	//  child exits, so fork (and exit) on the same thread.
+	runtime.LockOSThread()
+
+	for _, p := range s.programs {
+		if p.tty {
+			/*  Caught and dropped - ignored would be inherited.  */
+			signal.Notify(s.stops, jobControlSignals...)
+		}
+	}
+
+	signal.Notify(s.signals, forwardedSignals...)
+	if s.config.Upgrade.Signal != 0 {
+		signal.Notify(s.signals, s.config.Upgrade.Signal)
+	}
+
+	defer signal.Stop(s.signals)
+
+	s.schedule()
+
+	for !s.finished() {
+		select {
+		case sig := <-s.signals:
+			s.received(sig)
+
+		case exit := <-s.exits:
+			s.exited(exit)
+
+		case p := <-s.restarts:
+			if p.pending && !s.shutdown && !p.retired {
+				s.start(p)
+				s.schedule()
+			}
+
+		case r := <-s.readies:
+			s.readied(r)
+
+		case req := <-s.controls:
+			s.controlled(req)
+
+		case status := <-s.jobs:
+			s.jobControlled(status)
+
+		case <-s.stops: /*  Job control signal, don't stop.  */
+
+		case run := <-s.kills:
+			p := run.program
+			if p.pid > 0 && p.gen == run.gen {
+				fmt.Printf(" - %v did not stop in %v, killing it\n",
+					p.name, p.grace)
+				s.signal(p, syscall.SIGKILL)
+			}
+		}
+	}
+
+	s.teardown()
+	closeListeners(s.listeners)
+	if s.output != nil {
+		s.output.drain(outputDrainTimeout)
+	}
+
+	s.terminated()
+	if s.notifier != nil {
+		s.notifier.close()
+	}
+
+	if !s.config.PropagateExitCode && !s.override {
+		return 0
+	}
+
+	return s.exitCode
+
+} /*  End of method  supervisor.run.  */
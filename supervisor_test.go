@@ -0,0 +1,375 @@
+package reaper
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Make a wait status for an exit code or a signal.
+func waitStatus(code int, sig syscall.Signal) syscall.WaitStatus {
+	if sig != 0 {
+		return syscall.WaitStatus(sig)
+	}
+
+	return syscall.WaitStatus(code << 8)
+
+} /*  End of function  waitStatus.  */
+
+// Exit codes for exited and killed processes.
+func TestExitCode(t *testing.T) {
+	if code := exitCode(waitStatus(42, 0)); code != 42 {
+		t.Errorf("exit code = %d, expected 42", code)
+	}
+
+	if code := exitCode(waitStatus(0, syscall.SIGKILL)); code != 137 {
+		t.Errorf("killed exit code = %d, expected 137", code)
+	}
+
+} /*  End of function  TestExitCode.  */
+
+// Restart decisions for the restart policies.
+func TestShouldRestart(t *testing.T) {
+	tests := []struct {
+		policy   RestartPolicy
+		ws       syscall.WaitStatus
+		expected bool
+	}{
+		{"", waitStatus(1, 0), false},
+		{RestartNever, waitStatus(1, 0), false},
+		{RestartAlways, waitStatus(0, 0), true},
+		{RestartOnFailure, waitStatus(0, 0), false},
+		{RestartOnFailure, waitStatus(3, 0), true},
+		{RestartOnFailure, waitStatus(0, syscall.SIGTERM), true},
+	}
+
+	for _, tt := range tests {
+		p := &program{restart: RestartConfig{Policy: tt.policy}}
+		if restart := p.shouldRestart(tt.ws); restart != tt.expected {
+			t.Errorf("%q %v: restart = %v, expected %v", tt.policy,
+				tt.ws, restart, tt.expected)
+		}
+	}
+
+} /*  End of function  TestShouldRestart.  */
+
+// Exponential backoff capped at the max backoff and reset once the
+// program stays up long enough.
+func TestNextDelayBackoff(t *testing.T) {
+	now := time.Now()
+	p := &program{
+		restart: RestartConfig{Backoff: time.Second,
+			MaxBackoff: 10 * time.Second},
+		started: now,
+	}
+
+	expected := []time.Duration{1, 2, 4, 8, 10, 10}
+	for idx, secs := range expected {
+		delay, ok := p.nextDelay(now)
+		if !ok || delay != secs*time.Second {
+			t.Errorf("restart #%d: delay = %v, %v, expected %v", idx,
+				delay, ok, secs*time.Second)
+		}
+	}
+
+	//  Stayed up for the max backoff.
+	later := now.Add(10 * time.Second)
+	if delay, _ := p.nextDelay(later); delay != time.Second {
+		t.Errorf("delay after a long run = %v, expected 1s", delay)
+	}
+
+	p = &program{started: now}
+	if delay, _ := p.nextDelay(now); delay != defaultBackoff {
+		t.Errorf("default delay = %v, expected %v", delay, defaultBackoff)
+	}
+
+} /*  End of function  TestNextDelayBackoff.  */
+
+// Jitter adds up to the jitter fraction of the delay.
+func TestNextDelayJitter(t *testing.T) {
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		p := &program{
+			restart: RestartConfig{Backoff: time.Second, Jitter: 0.5},
+			started: now,
+		}
+
+		delay, _ := p.nextDelay(now)
+		if delay < time.Second || delay > 1500*time.Millisecond {
+			t.Fatalf("jittered delay = %v, expected 1s - 1.5s", delay)
+		}
+	}
+
+} /*  End of function  TestNextDelayJitter.  */
+
+// Crash loop detection - max restarts within the window.
+func TestNextDelayCrashLoop(t *testing.T) {
+	now := time.Now()
+	p := &program{
+		restart: RestartConfig{MaxRestarts: 3, Window: time.Minute},
+		started: now,
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, ok := p.nextDelay(now.Add(time.Duration(i) * time.Second)); !ok {
+			t.Fatalf("restart #%d: unexpected crash loop", i)
+		}
+	}
+
+	if _, ok := p.nextDelay(now.Add(3 * time.Second)); ok {
+		t.Errorf("expected a crash loop after 3 restarts in the window")
+	}
+
+	//  The earlier restarts fall out of the window.
+	if _, ok := p.nextDelay(now.Add(2 * time.Minute)); !ok {
+		t.Errorf("unexpected crash loop after the window")
+	}
+
+} /*  End of function  TestNextDelayCrashLoop.  */
+
+// Run a supervisor for the programs and return its exit code.
+func runSupervisor(t *testing.T, config Config) int {
+	s, err := newSupervisor(nil, config)
+	if err != nil {
+		t.Fatalf("new supervisor: %v", err)
+	}
+
+	done := make(chan int, 1)
+	go func() { done <- s.run() }()
+
+	select {
+	case code := <-done:
+		return code
+
+	case <-time.After(20 * time.Second):
+		t.Fatalf("supervisor did not finish")
+	}
+
+	return -1
+
+} /*  End of function  runSupervisor.  */
+
+// Make a shell program.
+func shellProgram(name, script string) Program {
+	return Program{Name: name, Path: "sh", Args: []string{"sh", "-c", script}}
+
+} /*  End of function  shellProgram.  */
+
+// Program defaults - name, args and the $PATH lookup.
+func TestMakeProgram(t *testing.T) {
+	config := MakeConfig()
+	p, err := makeProgram(config, Program{Path: "sh", Env: []string{"X=1"}})
+	if err != nil {
+		t.Fatalf("make program: %v", err)
+	}
+
+	if p.name != "sh" || len(p.args) != 1 || p.args[0] != "sh" ||
+		!strings.HasPrefix(p.path, "/") {
+		t.Errorf("program name = %q, path = %q, args = %v", p.name,
+			p.path, p.args)
+	}
+
+	env := strings.Join(p.attrs.Env, "\n")
+	if !strings.Contains(env, "\nX=1\n") ||
+		!strings.Contains(env, envIndicator(config)+"=") {
+		t.Errorf("program env is missing X=1 or the indicator")
+	}
+
+	_, err = makeProgram(config, Program{Path: "no-such-cmd-42"})
+	if err == nil {
+		t.Errorf("expected an error for an unknown program")
+	}
+
+} /*  End of function  TestMakeProgram.  */
+
+// The main program exiting stops the other programs, the parent exits
+// with its exit code only if it is propagated.
+func TestSupervisorMainExit(t *testing.T) {
+	for _, propagate := range []bool{false, true} {
+		config := MakeConfig()
+		config.Debug = false
+		config.PropagateExitCode = propagate
+		config.Programs = []Program{
+			shellProgram("sleeper", "exec sleep 30"),
+			shellProgram("main", "sleep 0.2; exit 3"),
+		}
+		config.Programs[1].Main = true
+
+		started := time.Now()
+		code := runSupervisor(t, config)
+
+		expected := 0
+		if propagate {
+			expected = 3
+		}
+
+		if code != expected {
+			t.Errorf("propagate %v: exit code = %d, expected %d",
+				propagate, code, expected)
+		}
+
+		if elapsed := time.Since(started); elapsed > 10*time.Second {
+			t.Errorf("sleeper was not stopped, took %v", elapsed)
+		}
+	}
+
+} /*  End of function  TestSupervisorMainExit.  */
+
+// Make programs with dependencies ("name:dep,dep").
+func dependentPrograms(specs ...string) []*program {
+	programs := make([]*program, 0, len(specs))
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, ":", 2)
+		p := &program{name: parts[0]}
+		if len(parts) > 1 {
+			p.depends = strings.Split(parts[1], ",")
+		}
+
+		programs = append(programs, p)
+	}
+
+	return programs
+
+} /*  End of function  dependentPrograms.  */
+
+// Resolve the dependencies - duplicates, unknown ones and cycles fail.
+func TestResolveDependencies(t *testing.T) {
+	tests := []struct {
+		specs []string
+		fail  string
+	}{
+		{[]string{"db", "app:db", "proxy:app,db"}, ""},
+		{[]string{"db", "db"}, "duplicate"},
+		{[]string{"app:db"}, "unknown"},
+		{[]string{"a:b", "b:c", "c:a"}, "cycle"},
+		{[]string{"a:a"}, "cycle"},
+	}
+
+	for _, tt := range tests {
+		programs := dependentPrograms(tt.specs...)
+		err := resolveDependencies(programs)
+		if len(tt.fail) == 0 && err != nil {
+			t.Errorf("%v: unexpected error: %v", tt.specs, err)
+		}
+
+		if len(tt.fail) > 0 &&
+			(err == nil || !strings.Contains(err.Error(), tt.fail)) {
+			t.Errorf("%v: error = %v, expected %q", tt.specs, err,
+				tt.fail)
+		}
+	}
+
+	programs := dependentPrograms("db", "app:db", "proxy:app,db")
+	resolveDependencies(programs)
+	if deps := programs[2].deps; len(deps) != 2 ||
+		deps[0] != programs[1] || deps[1] != programs[0] {
+		t.Errorf("proxy dependencies not resolved: %v", deps)
+	}
+
+} /*  End of function  TestResolveDependencies.  */
+
+// Programs start in dependency order and stop in the reverse order.
+func TestSupervisorOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reaper-order")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	log := filepath.Join(dir, "log")
+	service := func(name string, deps ...string) Program {
+		ready := filepath.Join(dir, name+".ready")
+		script := fmt.Sprintf("trap 'echo stop-%[1]s >> %[2]s; exit 0' "+
+			"TERM; echo %[1]s >> %[2]s; touch %[3]s; "+
+			"while :; do sleep 0.05; done", name, log, ready)
+
+		p := shellProgram(name, script)
+		p.DependsOn = deps
+		p.Ready = ReadinessCheck{File: ready, Interval: 10 * time.Millisecond}
+		return p
+	}
+
+	config := MakeConfig()
+	config.Debug = false
+	config.Programs = []Program{
+		service("proxy", "app"),
+		service("app", "db"),
+		service("db"),
+		shellProgram("main", "sleep 0.5"),
+	}
+	config.Programs[3].Main = true
+	config.Programs[3].DependsOn = []string{"proxy"}
+
+	runSupervisor(t, config)
+
+	data, err := ioutil.ReadFile(log)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+
+	order := strings.Fields(string(data))
+	expected := []string{"db", "app", "proxy", "stop-proxy", "stop-app",
+		"stop-db"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("order = %v, expected %v", order, expected)
+	}
+
+} /*  End of function  TestSupervisorOrder.  */
+
+// SIGWINCH is not forwarded to the programs in the terminal foreground.
+func TestForwardWINCH(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reaper-winch")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	s := &supervisor{}
+	for _, tty := range []bool{false, true} {
+		marker := filepath.Join(dir, fmt.Sprintf("tty-%v", tty))
+		script := "trap 'touch " + marker + "; exit 0' WINCH; touch " +
+			marker + ".up; while :; do sleep 0.05; done"
+
+		cmd := exec.Command("sh", "-c", script)
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("start: %v", err)
+		}
+
+		defer cmd.Wait()
+		defer cmd.Process.Kill()
+
+		for i := 0; i < 250; i++ {
+			if _, err := os.Stat(marker + ".up"); err == nil {
+				break
+			}
+
+			time.Sleep(20 * time.Millisecond)
+		}
+
+		s.programs = append(s.programs, &program{
+			name: marker,
+			pid:  cmd.Process.Pid,
+			tty:  tty,
+		})
+	}
+
+	s.forward(syscall.SIGWINCH)
+	time.Sleep(500 * time.Millisecond)
+
+	for _, p := range s.programs {
+		_, err := os.Stat(p.name)
+		if forwarded := err == nil; forwarded == p.tty {
+			t.Errorf("tty %v: SIGWINCH forwarded = %v", p.tty, forwarded)
+		}
+	}
+
+} /*  End of function  TestForwardWINCH.  */
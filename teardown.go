@@ -0,0 +1,118 @@
+package reaper
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	// Default grace period for the leftover descendants to exit.
+	defaultTeardownGracePeriod = 5 * time.Second
+
+	// Interval for checking (and reaping) the leftover descendants.
+	teardownInterval = 50 * time.Millisecond
+)
+
+// Teardown configuration - once the forked child (or the programs) are
+// done, the parent signals any leftover descendants (orphans reparented to
+// it), waits for them to exit while reaping them, reports and kills the
+// ones left over and only then exits.
+type TeardownConfig struct {
+	// Enable the teardown of the leftover descendants.
+	Enable bool
+
+	// Signal sent to the leftover descendants, defaults to SIGTERM.
+	Signal syscall.Signal
+
+	// Grace period for them to exit before they are killed (default 5s).
+	GracePeriod time.Duration
+}
+
+// Event data for the teardown event.
+type TeardownInfo struct {
+	Signaled []ProcessInfo `json:"signaled"`
+	Leftover []ProcessInfo `json:"leftover"`
+}
+
+// Return our live (not zombie) descendants.
+func liveDescendants() []ProcessInfo {
+	tree, err := TreeOf(os.Getpid())
+	if err != nil {
+		return nil
+	}
+
+	self := os.Getpid()
+	live := make([]ProcessInfo, 0)
+	tree.Walk(func(node *ProcessNode) {
+		if node.Pid != self && node.State != "Z" {
+			live = append(live, node.ProcessInfo)
+		}
+	})
+
+	return live
+
+} /*  End of function  liveDescendants.  */
+
+// Tear down the leftover descendants.
+func (s *supervisor) teardown() {
+	config := s.config.Teardown
+	if !config.Enable {
+		return
+	}
+
+	sig := config.Signal
+	if sig == 0 {
+		sig = syscall.SIGTERM
+	}
+
+	grace := config.GracePeriod
+	if grace <= 0 {
+		grace = defaultTeardownGracePeriod
+	}
+
+	signaled := liveDescendants()
+	if len(signaled) == 0 {
+		return
+	}
+
+	for _, p := range signaled {
+		syscall.Kill(p.Pid, sig)
+	}
+
+	//  Reap them ourselves unless the reaper is waiting for any child.
+	reaping := s.reaper != nil && s.reaper.config.Pid == -1
+
+	leftover := signaled
+	deadline := time.Now().Add(grace)
+	for len(leftover) > 0 && time.Now().Before(deadline) {
+		time.Sleep(teardownInterval)
+		if !reaping {
+			var ws syscall.WaitStatus
+			for {
+				pid, err := syscall.Wait4(-1, &ws, syscall.WNOHANG, nil)
+				if pid <= 0 || err != nil {
+					break
+				}
+			}
+		}
+
+		leftover = liveDescendants()
+	}
+
+	for _, p := range leftover {
+		fmt.Printf(" - Reaper teardown: pid %d (%s) left over, killing it\n",
+			p.Pid, p.Comm)
+
+		syscall.Kill(p.Pid, syscall.SIGKILL)
+	}
+
+	info := TeardownInfo{Signaled: signaled, Leftover: leftover}
+	event := makeEvent(EventTeardown, os.Getpid(), info,
+		"teardown signaled %d descendants, %d left over", len(signaled),
+		len(leftover))
+
+	publish(s.config.EventChannel, event)
+
+} /*  End of method  supervisor.teardown.  */
@@ -0,0 +1,88 @@
+package reaper
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Check if a pid is in a process list.
+func hasPid(procs []ProcessInfo, pid int) bool {
+	for _, p := range procs {
+		if p.Pid == pid {
+			return true
+		}
+	}
+
+	return false
+
+} /*  End of function  hasPid.  */
+
+// Leftover descendants are signaled and the ones that don't exit in the
+// grace period are killed.
+func TestTeardown(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("no /proc")
+	}
+
+	attrs := &syscall.ProcAttr{Files: []uintptr{0, 1, 2}}
+	sleeper, err := syscall.ForkExec("/bin/sleep", []string{"sleep", "30"},
+		attrs)
+	if err != nil {
+		t.Fatalf("start sleeper: %v", err)
+	}
+
+	script := "trap '' TERM; while :; do sleep 0.05; done"
+	stubborn, err := syscall.ForkExec("/bin/sh",
+		[]string{"sh", "-c", script}, attrs)
+	if err != nil {
+		syscall.Kill(sleeper, syscall.SIGKILL)
+		t.Fatalf("start stubborn: %v", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	live := liveDescendants()
+	if !hasPid(live, sleeper) || !hasPid(live, stubborn) {
+		t.Errorf("live descendants = %+v, expected %d and %d", live,
+			sleeper, stubborn)
+	}
+
+	events := make(chan Event, 1)
+	s := &supervisor{}
+	s.config.EventChannel = events
+	s.config.Teardown = TeardownConfig{
+		Enable:      true,
+		GracePeriod: 300 * time.Millisecond,
+	}
+
+	s.teardown()
+
+	select {
+	case event := <-events:
+		info := event.Data.(TeardownInfo)
+		if event.Type != EventTeardown ||
+			!hasPid(info.Signaled, sleeper) ||
+			!hasPid(info.Signaled, stubborn) {
+			t.Errorf("teardown event = %+v", event)
+		}
+
+		if hasPid(info.Leftover, sleeper) ||
+			!hasPid(info.Leftover, stubborn) {
+			t.Errorf("leftover = %+v, expected only %d", info.Leftover,
+				stubborn)
+		}
+
+	default:
+		t.Errorf("no teardown event")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	syscall.Wait4(stubborn, nil, 0, nil)
+
+	if live := liveDescendants(); hasPid(live, stubborn) {
+		t.Errorf("stubborn descendant %d was not killed", stubborn)
+	}
+
+} /*  End of function  TestTeardown.  */
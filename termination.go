@@ -0,0 +1,147 @@
+package reaper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Format for the termination message.
+type TerminationFormat string
+
+const (
+	// Plain text message (ala the kubernetes /dev/termination-log).
+	TerminationMessage TerminationFormat = "message"
+
+	// Json encoded `Termination`.
+	TerminationJSON TerminationFormat = "json"
+
+	// Max size of a termination message - kubernetes truncates messages
+	// longer than 4096 bytes.
+	maxTerminationMessage = 4096
+)
+
+// Termination report configuration - the parent writes a termination
+// message for the forked child (or the program that caused the shutdown)
+// when it exits.
+type TerminationConfig struct {
+	// Path to write the message to (for example /dev/termination-log),
+	// disabled if empty.
+	Path string
+
+	// Message format, defaults to a plain text message.
+	Format TerminationFormat
+
+	// Number of the last stderr lines included in the message - the
+	// stderr of the child processes is piped through the parent for this.
+	StderrLines int
+}
+
+// Termination report for a child process.
+type Termination struct {
+	Program    string        `json:"program"`
+	Pid        int           `json:"pid"`
+	ExitCode   int           `json:"exitCode"`
+	Signal     string        `json:"signal,omitempty"`
+	CoreDumped bool          `json:"coreDumped"`
+	Started    time.Time     `json:"started"`
+	Exited     time.Time     `json:"exited"`
+	Runtime    time.Duration `json:"runtime"`
+	Stderr     []string      `json:"stderr,omitempty"`
+}
+
+// Make the termination report for a program.
+func makeTermination(p *program, stderr []string) Termination {
+	status := p.status
+	ws := status.WaitStatus
+
+	started := status.Started
+	if started.IsZero() {
+		started = p.started
+	}
+
+	exited := status.Reaped
+	if exited.IsZero() {
+		exited = time.Now()
+	}
+
+	t := Termination{
+		Program:  p.name,
+		Pid:      status.Pid,
+		ExitCode: p.exitCode,
+		Started:  started,
+		Exited:   exited,
+		Stderr:   stderr,
+	}
+
+	if !started.IsZero() {
+		t.Runtime = exited.Sub(started)
+	}
+
+	if ws.Signaled() {
+		t.Signal = unix.SignalName(ws.Signal())
+		t.CoreDumped = ws.CoreDump()
+	}
+
+	return t
+
+} /*  End of function  makeTermination.  */
+
+// Format the plain text termination message.
+func (t Termination) message() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s exited with code %d", t.Program, t.ExitCode)
+	if len(t.Signal) > 0 {
+		fmt.Fprintf(&b, " (%s", t.Signal)
+		if t.CoreDumped {
+			b.WriteString(", core dumped")
+		}
+
+		b.WriteString(")")
+	}
+
+	if t.Runtime > 0 {
+		fmt.Fprintf(&b, " after %v", t.Runtime.Round(time.Millisecond))
+	}
+
+	b.WriteString("\n")
+
+	//  Keep the most recent stderr lines that fit.
+	size := b.Len()
+	first := len(t.Stderr)
+	for first > 0 {
+		n := len(t.Stderr[first-1]) + 1
+		if size+n > maxTerminationMessage {
+			break
+		}
+
+		first--
+		size += n
+	}
+
+	for _, line := range t.Stderr[first:] {
+		b.WriteString(line + "\n")
+	}
+
+	return b.String()
+
+} /*  End of method  Termination.message.  */
+
+// Write the termination report.
+func writeTermination(config TerminationConfig, t Termination) error {
+	data := []byte(t.message())
+	if config.Format == TerminationJSON {
+		var err error
+		if data, err = json.Marshal(t); err != nil {
+			return err
+		}
+	}
+
+	return ioutil.WriteFile(config.Path, data, 0644)
+
+} /*  End of function  writeTermination.  */
@@ -0,0 +1,120 @@
+package reaper
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Termination report for an exited and a killed program.
+func TestMakeTermination(t *testing.T) {
+	started := time.Now().Add(-2 * time.Second)
+	p := &program{name: "app", exitCode: 3, started: started}
+	p.status = Status{Pid: 42, WaitStatus: waitStatus(3, 0)}
+
+	term := makeTermination(p, []string{"oops"})
+	if term.Program != "app" || term.Pid != 42 || term.ExitCode != 3 ||
+		len(term.Signal) > 0 || !term.Started.Equal(started) ||
+		term.Runtime < 2*time.Second {
+		t.Errorf("termination = %+v", term)
+	}
+
+	expected := "app exited with code 3"
+	if msg := term.message(); !strings.HasPrefix(msg, expected) ||
+		!strings.HasSuffix(msg, "\noops\n") {
+		t.Errorf("message = %q, expected %q ... oops", msg, expected)
+	}
+
+	reaped := started.Add(time.Second)
+	p.exitCode = 128 + int(syscall.SIGKILL)
+	p.status = Status{
+		Pid:        42,
+		WaitStatus: waitStatus(0, syscall.SIGKILL),
+		Started:    started,
+		Reaped:     reaped,
+	}
+
+	term = makeTermination(p, nil)
+	if term.Signal != "SIGKILL" || term.Runtime != time.Second {
+		t.Errorf("termination = %+v", term)
+	}
+
+	expected = "app exited with code 137 (SIGKILL) after 1s\n"
+	if msg := term.message(); msg != expected {
+		t.Errorf("message = %q, expected %q", msg, expected)
+	}
+
+} /*  End of function  TestMakeTermination.  */
+
+// Message truncation keeps the most recent stderr lines.
+func TestTerminationMessageTruncation(t *testing.T) {
+	lines := make([]string, 0, 100)
+	for i := 0; i < 100; i++ {
+		lines = append(lines, strings.Repeat(string(rune('a'+i%26)), 99))
+	}
+
+	term := Termination{Program: "app", ExitCode: 1, Stderr: lines}
+	msg := term.message()
+	if len(msg) > maxTerminationMessage {
+		t.Fatalf("message size = %d, max %d", len(msg),
+			maxTerminationMessage)
+	}
+
+	header := "app exited with code 1\n"
+	kept := (maxTerminationMessage - len(header)) / 100
+	expected := header + strings.Join(lines[100-kept:], "\n") + "\n"
+	if msg != expected {
+		t.Errorf("message kept %d lines, expected the last %d",
+			strings.Count(msg, "\n")-1, kept)
+	}
+
+	term.Stderr = []string{strings.Repeat("x", maxTerminationMessage)}
+	if msg := term.message(); msg != header {
+		t.Errorf("message = %q, expected %q", msg, header)
+	}
+
+} /*  End of function  TestTerminationMessageTruncation.  */
+
+// Write the termination report as a message and as json.
+func TestWriteTermination(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reaper-termination")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	term := Termination{Program: "app", ExitCode: 2, Stderr: []string{"x"}}
+	config := TerminationConfig{Path: filepath.Join(dir, "termination")}
+
+	if err := writeTermination(config, term); err != nil {
+		t.Fatalf("write termination: %v", err)
+	}
+
+	data, _ := ioutil.ReadFile(config.Path)
+	if string(data) != term.message() {
+		t.Errorf("message = %q, expected %q", data, term.message())
+	}
+
+	config.Format = TerminationJSON
+	if err := writeTermination(config, term); err != nil {
+		t.Fatalf("write termination: %v", err)
+	}
+
+	var decoded Termination
+	data, _ = ioutil.ReadFile(config.Path)
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("json report %q: %v", data, err)
+	}
+
+	if !reflect.DeepEqual(decoded, term) {
+		t.Errorf("json report = %+v, expected %+v", decoded, term)
+	}
+
+} /*  End of function  TestWriteTermination.  */
@@ -108,6 +108,46 @@ function _errorExitEnabled() {
 }  #  End of function  _errorExitEnabled.
 
 
+#
+#  Returns 0 if PropagateExitCode is set in the associated config.
+#
+function _propagateExitCodeEnabled() {
+    local config=${1:-""}
+
+    if [ -z "${config}" ]; then
+        return 1
+    fi
+
+    local cfgjson="${SCRIPT_DIR}/fixtures/config/${config}"
+    if [ -f "${cfgjson}" ]; then
+        if grep -Ee '"PropagateExitCode":\s*true' "${cfgjson}" ; then
+            return 0
+        fi
+    fi
+
+    return 1
+
+}  #  End of function  _propagateExitCodeEnabled.
+
+
+#
+#  Check the container exit code is the (64-78) exit code of the child.
+#
+function _check_container_exit_code() {
+    local code=""
+    code=$(docker inspect "$1" -f '{{ .State.ExitCode }}')
+
+    echo "  - Container exit code = ${code}"
+    if [ "${code}" -lt 64 ] || [ "${code}" -gt 78 ]; then
+        echo "ERROR: Expected the child's exit code (64-78), got ${code}"
+        return 1
+    fi
+
+    return 0
+
+}  #  End of function  _check_container_exit_code.
+
+
 #
 #  Save container worker logs.
 #
@@ -326,6 +366,14 @@ function _run_test_scenario() {
         echo "  - Send child signal SIGTERM"
         docker exec "${elcid}" /reaper/bin/send-child-signal.sh TERM
         sleep 1.42
+
+        if _propagateExitCodeEnabled "${config}" &&  \
+           ! _check_container_exit_code "${elcid}"; then
+            _terminate_container "${elcid}"
+            echo ""
+            echo "FAIL: Some tests failed - (2/2)"
+            exit 65
+        fi
     fi
 
     #  Do the cleanup.
@@ -30,6 +30,7 @@ type TestOptions struct {
 	StatusClose          bool
 	ErrorExit            bool
 	RunForked            bool
+	PropagateExitCode    bool
 	WithReaper           bool
 	WithReaperOption     string
 }
@@ -289,6 +290,7 @@ func configure(options *TestOptions) reaper.Config {
 		StatusChannel:        statusChannel,
 		CloneEnvIndicator:    "_REAPER_TEST",
 		DisableCallerCheck:   false,
+		PropagateExitCode:    options.PropagateExitCode,
 	}
 
 } /*  End of function  configure.  */
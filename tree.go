@@ -0,0 +1,85 @@
+package reaper
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Process tree node - serialises to JSON with the process information
+// fields inlined alongside the children.
+type ProcessNode struct {
+	ProcessInfo
+	Children []*ProcessNode `json:"children,omitempty"`
+}
+
+// Build the process tree rooted at pid from a list of processes.
+func buildTree(pid int, procs []ProcessInfo) (*ProcessNode, error) {
+	nodes := make(map[int]*ProcessNode, len(procs))
+	for _, p := range procs {
+		nodes[p.Pid] = &ProcessNode{ProcessInfo: p}
+	}
+
+	root, ok := nodes[pid]
+	if !ok {
+		return nil, fmt.Errorf("no such process %d", pid)
+	}
+
+	for _, node := range nodes {
+		if node.Pid == pid {
+			continue
+		}
+
+		if parent, ok := nodes[node.PPid]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+
+	for _, node := range nodes {
+		sort.Slice(node.Children, func(i, j int) bool {
+			return node.Children[i].Pid < node.Children[j].Pid
+		})
+	}
+
+	return root, nil
+
+} /*  End of function  buildTree.  */
+
+/*
+ *  ======================================================================
+ *  Section: Exported functions
+ *  ======================================================================
+ */
+
+// Return the descendant process tree rooted at the current process - aka
+// the reaper or the forked child process (if called from the child).
+func Tree() (*ProcessNode, error) {
+	return TreeOf(os.Getpid())
+
+} /*  End of [exported] function  Tree.  */
+
+// Return the descendant process tree rooted at a specific pid.
+func TreeOf(pid int) (*ProcessNode, error) {
+	procs, err := listProcesses()
+	if err != nil {
+		return nil, err
+	}
+
+	return buildTree(pid, procs)
+
+} /*  End of [exported] function  TreeOf.  */
+
+// Walk the tree depth-first, calling fn for each node after its children
+// have been visited (aka leaves first) - the order in which to shut down.
+func (n *ProcessNode) Walk(fn func(node *ProcessNode)) {
+	if n == nil {
+		return
+	}
+
+	for _, child := range n.Children {
+		child.Walk(fn)
+	}
+
+	fn(n)
+
+} /*  End of [exported] method  ProcessNode.Walk.  */
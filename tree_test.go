@@ -0,0 +1,77 @@
+package reaper
+
+import (
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+// Build a tree from a process list and walk it leaves first.
+func TestBuildTree(t *testing.T) {
+	procs := []ProcessInfo{
+		{Pid: 1, PPid: 0},
+		{Pid: 10, PPid: 1},
+		{Pid: 30, PPid: 10},
+		{Pid: 20, PPid: 10},
+		{Pid: 21, PPid: 20},
+		{Pid: 40, PPid: 1},
+		{Pid: 50, PPid: 99}, /*  Parent not in the list.  */
+	}
+
+	root, err := buildTree(10, procs)
+	if err != nil {
+		t.Fatalf("build tree: %v", err)
+	}
+
+	pids := make([]int, 0)
+	root.Walk(func(node *ProcessNode) {
+		pids = append(pids, node.Pid)
+	})
+
+	if expected := []int{21, 20, 30, 10}; !reflect.DeepEqual(pids, expected) {
+		t.Errorf("walk order = %v, expected %v", pids, expected)
+	}
+
+	if _, err := buildTree(42, procs); err == nil {
+		t.Errorf("expected an error for a missing root")
+	}
+
+	var empty *ProcessNode
+	empty.Walk(func(node *ProcessNode) {
+		t.Errorf("walked a nil tree")
+	})
+
+} /*  End of function  TestBuildTree.  */
+
+// Our tree has a child we started.
+func TestTree(t *testing.T) {
+	if _, err := os.Stat(procRoot); err != nil {
+		t.Skip("no /proc filesystem")
+	}
+
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start sleep: %v", err)
+	}
+
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	root, err := Tree()
+	if err != nil {
+		t.Fatalf("tree: %v", err)
+	}
+
+	found := false
+	for _, child := range root.Children {
+		found = found || child.Pid == cmd.Process.Pid
+	}
+
+	if root.Pid != os.Getpid() || !found {
+		t.Errorf("tree %+v is missing child %d", root, cmd.Process.Pid)
+	}
+
+} /*  End of function  TestTree.  */
@@ -0,0 +1,229 @@
+package reaper
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+)
+
+const (
+	// Default time to wait for the upgraded child to become ready.
+	defaultUpgradeTimeout = time.Minute
+
+	// Default time to wait for the replaced (or failed) copy to stop.
+	defaultUpgradeStopGrace = 10 * time.Second
+)
+
+// Zero-downtime upgrade configuration - on the upgrade signal, the parent
+// starts a fresh copy of the forked child (or the main program), waits for
+// it to become ready and then stops the old one. If the new child doesn't
+// become ready in time, it is stopped and the old one keeps running. The
+// new child is ready as per its readiness checks, its control channel (if
+// enabled) or else as soon as it starts.
+type UpgradeConfig struct {
+	// Upgrade signal (for example SIGHUP or SIGUSR2), disabled if 0.
+	Signal syscall.Signal
+
+	// Max time to wait for the new child to become ready (default 1m).
+	Timeout time.Duration
+
+	// Max time to wait for the replaced (or failed) copy to stop before
+	// it is killed (default 10s), unless the program has its own stop
+	// grace period.
+	StopGracePeriod time.Duration
+}
+
+// Event data for the upgrade events.
+type UpgradeInfo struct {
+	Name   string `json:"name"`
+	OldPid int    `json:"oldPid"`
+	NewPid int    `json:"newPid"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Return a (not started) copy of a program.
+func (p *program) clone() *program {
+	return &program{
+		name:    p.name,
+		path:    p.path,
+		args:    p.args,
+		attrs:   p.attrs,
+		opened:  p.opened,
+		restart: p.restart,
+		stopSig: p.stopSig,
+		grace:   p.grace,
+		nice:    p.nice,
+		tty:     p.tty,
+		main:    p.main,
+		vital:   p.vital,
+		ready:   p.ready,
+		depends: p.depends,
+		deps:    p.deps,
+	}
+
+} /*  End of method  program.clone.  */
+
+// Return the program to upgrade - the main program.
+func (s *supervisor) upgradable() *program {
+	for _, p := range s.programs {
+		if p.main {
+			return p
+		}
+	}
+
+	return nil
+
+} /*  End of method  supervisor.upgradable.  */
+
+// Start an upgrade - a fresh copy of the main program.
+func (s *supervisor) upgrade() {
+	old := s.upgradable()
+	if s.shutdown || s.upgrading != nil || old == nil || old.pid <= 0 {
+		fmt.Printf(" - Reaper upgrade not possible now, ignored\n")
+		return
+	}
+
+	next := old.clone()
+	next.upgrade = old
+	s.upgrading = next
+
+	if s.config.Debug {
+		fmt.Printf(" - Reaper upgrading %v pid %d ...\n", old.name, old.pid)
+	}
+
+	s.start(next)
+	if next.pid <= 0 {
+		return /*  Failed to start, the exit handles the rollback.  */
+	}
+
+	if next.isReady && s.config.Control.Enable {
+		/*  Wait for the new child to report it is ready.  */
+		next.isReady = false
+	}
+
+	if next.isReady {
+		s.promote(next)
+		return
+	}
+
+	timeout := s.config.Upgrade.Timeout
+	if timeout <= 0 {
+		timeout = defaultUpgradeTimeout
+	}
+
+	run := readiness{program: next, gen: next.gen, ready: false}
+	time.AfterFunc(timeout, func() { s.readies <- run })
+
+} /*  End of method  supervisor.upgrade.  */
+
+// Publish an upgrade event.
+func (s *supervisor) upgraded(etype EventType, next *program, reason string) {
+	info := UpgradeInfo{
+		Name:   next.name,
+		OldPid: next.upgrade.pid,
+		NewPid: next.pid,
+		Reason: reason,
+	}
+
+	if info.NewPid <= 0 {
+		/*  Already exited (and reaped).  */
+		info.NewPid = next.status.Pid
+	}
+
+	event := makeEvent(etype, info.NewPid, info, "upgrade %v pid %d -> %d",
+		info.Name, info.OldPid, info.NewPid)
+
+	if len(reason) > 0 {
+		event.Message += " failed: " + reason
+	}
+
+	publish(s.config.EventChannel, event)
+
+} /*  End of method  supervisor.upgraded.  */
+
+// The upgraded program is ready - replace the old one and stop it.
+func (s *supervisor) promote(next *program) {
+	old := next.upgrade
+	s.upgraded(EventUpgrade, next, "")
+
+	if s.config.Debug {
+		fmt.Printf(" - Reaper upgraded %v pid %d -> %d\n", next.name,
+			old.pid, next.pid)
+	}
+
+	for idx, p := range s.programs {
+		if p == old {
+			s.programs[idx] = next
+		}
+
+		for depIdx, dep := range p.deps {
+			if dep == old {
+				p.deps[depIdx] = next
+			}
+		}
+	}
+
+	next.upgrade = nil
+	s.upgrading = nil
+
+	old.done = true
+	s.retire(old)
+	s.notifyStopping(old)
+	s.stopRetired(old)
+
+} /*  End of method  supervisor.promote.  */
+
+// The upgraded program failed to become ready - stop it and keep the old
+// one running.
+func (s *supervisor) rollback(next *program, reason string) {
+	fmt.Printf(" - Error: upgrade of %v failed (%v), rolling back\n",
+		next.name, reason)
+
+	s.upgraded(EventUpgradeFailed, next, reason)
+
+	s.upgrading = nil
+	s.retire(next)
+	s.stopRetired(next)
+
+} /*  End of method  supervisor.rollback.  */
+
+// Stop a retired copy of an upgraded program - it is killed if it doesn't
+// stop within the upgrade stop grace period, as the supervisor waits for
+// the retired copies to exit.
+func (s *supervisor) stopRetired(p *program) {
+	if p.grace <= 0 {
+		p.grace = s.config.Upgrade.StopGracePeriod
+		if p.grace <= 0 {
+			p.grace = defaultUpgradeStopGrace
+		}
+	}
+
+	s.terminate(p, syscall.SIGTERM)
+
+} /*  End of method  supervisor.stopRetired.  */
+
+// Handle the exit of the old or new copy of an upgraded program.
+func (s *supervisor) upgradeExited(p *program, exit programExit) {
+	if s.config.Debug {
+		fmt.Printf(" - reaper child %v (upgrade) pid %d exited, code = %d\n",
+			p.name, exit.status.Pid, exitCode(exit.status.WaitStatus))
+	}
+
+	//  Reaped, so don't signal (or kill) it after its grace period.
+	p.pid = 0
+	p.status = exit.status
+
+	if s.upgrading == p {
+		reason := fmt.Sprintf("exited with code %d",
+			exitCode(exit.status.WaitStatus))
+		s.rollback(p, reason)
+	}
+
+	for idx, q := range s.retired {
+		if q == p {
+			s.retired = append(s.retired[:idx], s.retired[idx+1:]...)
+			break
+		}
+	}
+
+} /*  End of method  supervisor.upgradeExited.  */
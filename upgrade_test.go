@@ -0,0 +1,133 @@
+package reaper
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// A clone has the configuration of the program, but none of its state.
+func TestProgramClone(t *testing.T) {
+	p := &program{
+		name:    "app",
+		path:    "/bin/app",
+		args:    []string{"app", "-v"},
+		attrs:   &syscall.ProcAttr{Dir: "/"},
+		opened:  []*os.File{os.Stdin},
+		restart: RestartConfig{Policy: RestartAlways},
+		stopSig: syscall.SIGINT,
+		grace:   time.Second,
+		nice:    5,
+		tty:     true,
+		main:    true,
+		vital:   true,
+		depends: []string{"db"},
+		deps:    []*program{{name: "db"}},
+	}
+
+	clone := p.clone()
+	if !reflect.DeepEqual(clone, p) {
+		t.Errorf("clone = %+v, expected %+v", clone, p)
+	}
+
+	p.pid = 42
+	p.started = time.Now()
+	if clone := p.clone(); clone.pid != 0 || !clone.started.IsZero() {
+		t.Errorf("clone has the program state: %+v", clone)
+	}
+
+} /*  End of function  TestProgramClone.  */
+
+// The replaced copy is killed if it ignores SIGTERM.
+func TestUpgradeStopGracePeriod(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reaper-upgrade")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	//  The first copy ignores SIGTERM, the upgraded copy exits shortly.
+	first := filepath.Join(dir, "first")
+	script := "if [ -e " + first + " ]; then sleep 0.5; exit 0; fi; " +
+		"trap '' TERM; touch " + first + "; " +
+		"while :; do sleep 0.05; done"
+
+	config := MakeConfig()
+	config.Debug = false
+	config.Programs = []Program{shellProgram("main", script)}
+	config.Programs[0].Main = true
+	config.Upgrade = UpgradeConfig{
+		Signal:          syscall.SIGUSR2,
+		StopGracePeriod: 200 * time.Millisecond,
+	}
+
+	go func() {
+		for deadline := time.Now().Add(5 * time.Second); ; {
+			if _, err := os.Stat(first); err == nil {
+				syscall.Kill(os.Getpid(), syscall.SIGUSR2)
+				return
+			}
+
+			if time.Now().After(deadline) {
+				return
+			}
+
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+
+	started := time.Now()
+	runSupervisor(t, config)
+
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("replaced copy was not killed, took %v", elapsed)
+	}
+
+} /*  End of function  TestUpgradeStopGracePeriod.  */
+
+// An exited copy of an upgraded program is not signaled (or killed) after
+// its grace period - its pid may already be reused.
+func TestUpgradeExited(t *testing.T) {
+	events := make(chan Event, 1)
+	s := &supervisor{kills: make(chan programRun, 2)}
+	s.config.EventChannel = events
+	s.config.Upgrade.StopGracePeriod = 10 * time.Millisecond
+
+	old := &program{name: "main", pid: 42, retired: true}
+	next := &program{name: "main", pid: 43, upgrade: old}
+	s.retired = []*program{old}
+	s.upgrading = next
+
+	s.exited(programExit{program: old, status: Status{Pid: 42}})
+	if old.pid != 0 || len(s.retired) != 0 {
+		t.Errorf("retired copy pid = %d, retired = %v", old.pid, s.retired)
+	}
+
+	s.exited(programExit{program: next, status: Status{Pid: 43}})
+	if next.pid != 0 || s.upgrading != nil {
+		t.Errorf("upgrading copy pid = %d, upgrading = %v", next.pid,
+			s.upgrading)
+	}
+
+	select {
+	case event := <-events:
+		info := event.Data.(UpgradeInfo)
+		if event.Type != EventUpgradeFailed || info.NewPid != 43 {
+			t.Errorf("upgrade event = %+v", event)
+		}
+
+	default:
+		t.Errorf("no upgrade failed event")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if len(s.kills) > 0 {
+		t.Errorf("exited copy is going to be killed")
+	}
+
+} /*  End of function  TestUpgradeExited.  */
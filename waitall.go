@@ -0,0 +1,90 @@
+package reaper
+
+import (
+	"context"
+	"fmt"
+)
+
+// Waiter (in WaitAll) for all the children to be reaped.
+type allWaiter struct {
+	statuses []Status
+	done     chan struct{}
+}
+
+// Collect a reaped child status for the waiters.
+func (r *Reaper) collect(status Status) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, w := range r.waiters {
+		w.statuses = append(w.statuses, status)
+	}
+
+} /*  End of method  Reaper.collect.  */
+
+// No children left to reap - release the waiters.
+func (r *Reaper) drained() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, w := range r.waiters {
+		close(w.done)
+	}
+
+	r.waiters = nil
+
+} /*  End of method  Reaper.drained.  */
+
+// Remove a waiter (if it is still waiting).
+func (r *Reaper) unwait(waiter *allWaiter) {
+	for idx, w := range r.waiters {
+		if w == waiter {
+			r.waiters = append(r.waiters[:idx], r.waiters[idx+1:]...)
+			return
+		}
+	}
+
+} /*  End of method  Reaper.unwait.  */
+
+/*
+ *  ======================================================================
+ *  Section: Exported functions
+ *  ======================================================================
+ */
+
+// Wait until there are no children left to reap (ECHILD) or until the
+// context is done and return the statuses of the children reaped in the
+// meantime. The error is the context's error if it was done first. With
+// the child subreaper enabled, this drains the whole descendant tree.
+// Returns an error for a disabled reaper (a nil handle).
+func (r *Reaper) WaitAll(ctx context.Context) ([]Status, error) {
+	if r == nil {
+		return []Status{}, fmt.Errorf("reaper is disabled")
+	}
+
+	waiter := &allWaiter{
+		statuses: make([]Status, 0),
+		done:     make(chan struct{}),
+	}
+
+	r.mutex.Lock()
+	r.waiters = append(r.waiters, waiter)
+	r.mutex.Unlock()
+
+	//  Nudge the reaper, there may be no SIGCHLD coming.
+	r.sweep()
+
+	select {
+	case <-waiter.done:
+		return waiter.statuses, nil
+
+	case <-ctx.Done():
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+
+		r.unwait(waiter)
+
+		return waiter.statuses, ctx.Err()
+	}
+
+} /*  End of [exported] method  Reaper.WaitAll.  */
@@ -0,0 +1,196 @@
+package reaper
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Negligent parent watchdog configuration. The watchdog periodically
+// scans /proc for zombies whose parent is not the reaper and flags any
+// parent that holds on to too many or too old zombies.
+type WatchdogConfig struct {
+	// Scan interval, the watchdog is disabled if this is zero.
+	Interval time.Duration
+
+	// Max zombies a parent can hold before it is flagged (0 = no limit).
+	MaxZombies int
+
+	// Max age of a zombie before its parent is flagged (0 = no limit).
+	MaxZombieAge time.Duration
+
+	// Send a SIGCHLD to the negligent parent to nudge it to reap.
+	NudgeParent bool
+
+	// Signal sent to kill the negligent parent (0 = disabled), so that
+	// its zombies get reparented to and reaped by us. The programs
+	// supervised in forked mode are never killed, only flagged.
+	KillSignal syscall.Signal
+}
+
+// Event data for `EventNegligentParent` events.
+type NegligentParent struct {
+	Parent    ProcessInfo   `json:"parent"`
+	Zombies   []ProcessInfo `json:"zombies"`
+	OldestAge time.Duration `json:"oldestAge"`
+	Actions   []string      `json:"actions,omitempty"`
+}
+
+// Identity of a zombie process - pids get recycled.
+type zombieKey struct {
+	pid   int
+	ticks uint64
+}
+
+// Negligent parent watchdog.
+type watchdog struct {
+	config    WatchdogConfig
+	reaper    *Reaper
+	mutex     sync.Mutex
+	firstSeen map[zombieKey]time.Time
+}
+
+// Make a new watchdog for the reaper.
+func newWatchdog(r *Reaper) *watchdog {
+	return &watchdog{
+		config:    r.config.Watchdog,
+		reaper:    r,
+		firstSeen: make(map[zombieKey]time.Time),
+	}
+
+} /*  End of function  newWatchdog.  */
+
+// Scan for zombies and flag [and optionally act on] negligent parents.
+func (w *watchdog) scan() {
+	zombies, err := ScanZombies()
+	if err != nil {
+		if w.reaper.config.Debug {
+			fmt.Printf(" - Watchdog zombie scan error: %v\n", err)
+		}
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	now := time.Now()
+	seen := make(map[zombieKey]time.Time, len(zombies))
+	parents := make(map[int][]ProcessInfo)
+
+	for _, z := range zombies {
+		key := zombieKey{pid: z.Pid, ticks: z.startTicks}
+		since, ok := w.firstSeen[key]
+		if !ok {
+			since = now
+		}
+
+		seen[key] = since
+
+		//  Our own zombies are taken care of by the reaper.
+		if z.PPid > 0 && z.PPid != w.reaper.pid {
+			parents[z.PPid] = append(parents[z.PPid], z)
+		}
+	}
+
+	w.firstSeen = seen
+
+	for ppid, kids := range parents {
+		oldest := time.Duration(0)
+		for _, z := range kids {
+			key := zombieKey{pid: z.Pid, ticks: z.startTicks}
+			if age := now.Sub(seen[key]); age > oldest {
+				oldest = age
+			}
+		}
+
+		tooMany := w.config.MaxZombies > 0 && len(kids) > w.config.MaxZombies
+		tooOld := w.config.MaxZombieAge > 0 && oldest > w.config.MaxZombieAge
+		if tooMany || tooOld {
+			w.flag(ppid, kids, oldest)
+		}
+	}
+
+} /*  End of method  watchdog.scan.  */
+
+// Flag a negligent parent and take any configured actions.
+func (w *watchdog) flag(ppid int, zombies []ProcessInfo, oldest time.Duration) {
+	parent, err := readProcess(ppid)
+	if err != nil {
+		parent = ProcessInfo{Pid: ppid}
+	}
+
+	data := NegligentParent{
+		Parent:    parent,
+		Zombies:   zombies,
+		OldestAge: oldest,
+	}
+
+	//  Don't go around killing init, our own parent or the supervised
+	//  programs (forked mode) - that would take down the app.
+	safe := ppid > 1 && ppid != os.Getppid() && !w.reaper.isSupervised(ppid)
+
+	if safe && w.config.NudgeParent {
+		if err := syscall.Kill(ppid, syscall.SIGCHLD); err == nil {
+			data.Actions = append(data.Actions, "nudged")
+		}
+	}
+
+	if safe && w.config.KillSignal != 0 {
+		if err := syscall.Kill(ppid, w.config.KillSignal); err == nil {
+			action := fmt.Sprintf("killed:%v", w.config.KillSignal)
+			data.Actions = append(data.Actions, action)
+		}
+	}
+
+	if w.reaper.config.Debug {
+		fmt.Printf(" - Watchdog: parent %d (%s) has %d zombies, "+
+			"oldest %v, actions %v\n", ppid, parent.Comm,
+			len(zombies), oldest, data.Actions)
+	}
+
+	event := makeEvent(EventNegligentParent, ppid, data,
+		"parent %d (%s) has %d zombies, oldest %v", ppid,
+		parent.Comm, len(zombies), oldest)
+
+	publish(w.reaper.config.EventChannel, event)
+
+} /*  End of method  watchdog.flag.  */
+
+// Mark a pid as supervised (or not) - the watchdog leaves it alone.
+func (r *Reaper) supervise(pid int, supervised bool) {
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if supervised {
+		r.supervised[pid] = true
+	} else {
+		delete(r.supervised, pid)
+	}
+
+} /*  End of method  Reaper.supervise.  */
+
+// Check if a pid is supervised.
+func (r *Reaper) isSupervised(pid int) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.supervised[pid]
+
+} /*  End of method  Reaper.isSupervised.  */
+
+// Run the watchdog periodically.
+func (w *watchdog) run() {
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		w.scan()
+	}
+
+} /*  End of method  watchdog.run.  */
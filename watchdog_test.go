@@ -0,0 +1,65 @@
+package reaper
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+// Flag a parent and return the actions taken.
+func flagActions(t *testing.T, w *watchdog, pid int) []string {
+	w.flag(pid, nil, 0)
+
+	select {
+	case event := <-w.reaper.config.EventChannel:
+		return event.Data.(NegligentParent).Actions
+
+	default:
+		t.Fatalf("no negligent parent event published for pid %d", pid)
+	}
+
+	return nil
+
+} /*  End of function  flagActions.  */
+
+// The watchdog never kills the supervised programs.
+func TestWatchdogSparesSupervised(t *testing.T) {
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start sleep: %v", err)
+	}
+
+	defer cmd.Process.Kill()
+
+	pid := cmd.Process.Pid
+
+	r := &Reaper{
+		config:     Config{EventChannel: make(chan Event, 1)},
+		supervised: make(map[int]bool),
+	}
+
+	w := &watchdog{
+		config: WatchdogConfig{KillSignal: syscall.SIGKILL},
+		reaper: r,
+	}
+
+	r.supervise(pid, true)
+	if actions := flagActions(t, w, pid); len(actions) != 0 {
+		t.Errorf("supervised pid %d actions = %v, expected none", pid,
+			actions)
+	}
+
+	if err := syscall.Kill(pid, 0); err != nil {
+		t.Fatalf("supervised pid %d was killed: %v", pid, err)
+	}
+
+	r.supervise(pid, false)
+	actions := flagActions(t, w, pid)
+	if len(actions) != 1 || actions[0] != "killed:killed" {
+		t.Errorf("unsupervised pid %d actions = %v, expected a kill", pid,
+			actions)
+	}
+
+	cmd.Wait()
+
+} /*  End of function  TestWatchdogSparesSupervised.  */